@@ -0,0 +1,245 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"semantic-auth/db"
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CalibrationOptions selects the attempts to calibrate against
+type CalibrationOptions struct {
+	Username string
+	From     time.Time
+	To       time.Time
+
+	// InferLabels labels attempts that have no operator label: an attempt is
+	// genuine if the same user logged in successfully within InferWindow after
+	// it. A rejected attempt with no such success is an impostor. An accepted
+	// attempt with none is left unlabeled, since it is usually just the
+	// user's last login and says nothing either way.
+	InferLabels bool
+	InferWindow time.Duration
+
+	// DefaultThreshold decides whether attempts recorded before thresholds
+	// were stored passed
+	DefaultThreshold float64
+
+	PerUser bool
+	Step    float64 // spacing of thresholds on the ROC curve
+}
+
+// ROCPoint is the error rates at one threshold
+type ROCPoint struct {
+	Threshold float64 `json:"threshold"`
+	FAR       float64 `json:"far"` // impostor attempts accepted
+	FRR       float64 `json:"frr"` // genuine attempts rejected
+}
+
+// Calibration is the ROC curve and equal-error-rate threshold for a set of labeled attempts
+type Calibration struct {
+	Username     string     `json:"username,omitempty"`
+	Genuine      int        `json:"genuine"`
+	Impostor     int        `json:"impostor"`
+	EERThreshold float64    `json:"eer_threshold"`
+	EER          float64    `json:"eer"`
+	Curve        []ROCPoint `json:"curve"`
+}
+
+// CalibrationReport is the overall calibration plus optional per-user results
+type CalibrationReport struct {
+	Overall  Calibration   `json:"overall"`
+	Users    []Calibration `json:"users,omitempty"`
+	Skipped  int           `json:"skipped"` // attempts left unlabeled, with or without inference
+	Inferred int           `json:"inferred"`
+}
+
+// LabeledScore is one attempt's similarity and whether it was genuine
+type LabeledScore struct {
+	Score   float64
+	Genuine bool
+}
+
+// Calibrate reads login attempts and computes error rates across thresholds
+func Calibrate(ctx context.Context, opts CalibrationOptions) (*CalibrationReport, error) {
+	filter := bson.M{}
+	if opts.Username != "" {
+		filter["username"] = opts.Username
+	}
+	timeFilter := bson.M{}
+	if !opts.From.IsZero() {
+		timeFilter["$gte"] = opts.From
+	}
+	if !opts.To.IsZero() {
+		timeFilter["$lte"] = opts.To
+	}
+	if len(timeFilter) > 0 {
+		filter["timestamp"] = timeFilter
+	}
+
+	cursor, err := db.Client.Database("semantic_auth").Collection("login_attempts").
+		Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("query login attempts: %w", err)
+	}
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, fmt.Errorf("read login attempts: %w", err)
+	}
+
+	return calibrateAttempts(attempts, opts), nil
+}
+
+// calibrateAttempts labels attempts, given in time order, and computes the report
+func calibrateAttempts(attempts []models.LoginAttempt, opts CalibrationOptions) *CalibrationReport {
+	report := &CalibrationReport{}
+	byUser := make(map[string][]LabeledScore)
+	var all []LabeledScore
+
+	// Attempts are in time order, so the successes that follow an attempt are later in its user's slice
+	attemptsByUser := make(map[string][]models.LoginAttempt)
+	for _, attempt := range attempts {
+		attemptsByUser[attempt.Username] = append(attemptsByUser[attempt.Username], attempt)
+	}
+
+	for username, userAttempts := range attemptsByUser {
+		for i, attempt := range userAttempts {
+			var genuine bool
+			switch attempt.Label {
+			case models.LabelGenuine:
+				genuine = true
+			case models.LabelImpostor:
+				genuine = false
+			default:
+				if !opts.InferLabels {
+					report.Skipped++
+					continue
+				}
+				switch inferLabel(userAttempts, i, opts) {
+				case models.LabelGenuine:
+					genuine = true
+				case models.LabelImpostor:
+					genuine = false
+				default:
+					report.Skipped++
+					continue
+				}
+				report.Inferred++
+			}
+
+			score := LabeledScore{Score: attempt.Similarity, Genuine: genuine}
+			all = append(all, score)
+			byUser[username] = append(byUser[username], score)
+		}
+	}
+
+	report.Overall = ComputeCalibration(all, opts.Step)
+
+	if opts.PerUser {
+		for username, scores := range byUser {
+			calibration := ComputeCalibration(scores, opts.Step)
+			calibration.Username = username
+			report.Users = append(report.Users, calibration)
+		}
+		sort.Slice(report.Users, func(i, j int) bool {
+			return report.Users[i].Username < report.Users[j].Username
+		})
+	}
+
+	return report
+}
+
+// inferLabel applies the later-success heuristic to attempts[i], looking
+// only at the attempts after it. It returns "" when there is no evidence
+// either way.
+func inferLabel(attempts []models.LoginAttempt, i int, opts CalibrationOptions) string {
+	deadline := attempts[i].Timestamp.Add(opts.InferWindow)
+	for _, later := range attempts[i+1:] {
+		if later.Timestamp.After(deadline) {
+			break
+		}
+		if passed(later, opts.DefaultThreshold) {
+			return models.LabelGenuine
+		}
+	}
+	if passed(attempts[i], opts.DefaultThreshold) {
+		return ""
+	}
+	return models.LabelImpostor
+}
+
+// passed reports whether an attempt was accepted when it was made
+func passed(attempt models.LoginAttempt, defaultThreshold float64) bool {
+	if attempt.Threshold == 0 {
+		return attempt.Similarity >= defaultThreshold
+	}
+	return attempt.Passed
+}
+
+// ComputeCalibration builds the ROC curve over thresholds spaced by step and
+// finds the threshold where the false-accept and false-reject rates are closest
+func ComputeCalibration(scores []LabeledScore, step float64) Calibration {
+	if step <= 0 {
+		step = 0.01
+	}
+
+	var genuine, impostor []float64
+	for _, s := range scores {
+		if s.Genuine {
+			genuine = append(genuine, s.Score)
+		} else {
+			impostor = append(impostor, s.Score)
+		}
+	}
+	sort.Float64s(genuine)
+	sort.Float64s(impostor)
+
+	calibration := Calibration{
+		Genuine:  len(genuine),
+		Impostor: len(impostor),
+		Curve:    []ROCPoint{},
+	}
+	if len(genuine) == 0 || len(impostor) == 0 {
+		return calibration
+	}
+
+	// Cover every observed score, rounded out to whole steps
+	low := math.Floor(math.Min(genuine[0], impostor[0])/step) * step
+	high := math.Ceil(math.Max(genuine[len(genuine)-1], impostor[len(impostor)-1])/step)*step + step
+
+	bestGap := math.Inf(1)
+	for n := 0; ; n++ {
+		threshold := low + float64(n)*step
+		if threshold > high+step/2 {
+			break
+		}
+		threshold = math.Round(threshold*1e6) / 1e6
+
+		point := ROCPoint{
+			Threshold: threshold,
+			FAR:       1 - fractionBelow(impostor, threshold),
+			FRR:       fractionBelow(genuine, threshold),
+		}
+		calibration.Curve = append(calibration.Curve, point)
+
+		if gap := math.Abs(point.FAR - point.FRR); gap < bestGap {
+			bestGap = gap
+			calibration.EERThreshold = threshold
+			calibration.EER = (point.FAR + point.FRR) / 2
+		}
+	}
+
+	return calibration
+}
+
+// fractionBelow returns the share of sorted values strictly below threshold
+func fractionBelow(sorted []float64, threshold float64) float64 {
+	return float64(sort.SearchFloat64s(sorted, threshold)) / float64(len(sorted))
+}
@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"semantic-auth/models"
+)
+
+func TestInferLabel(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	attempt := func(minutes int, passed bool) models.LoginAttempt {
+		similarity := 0.5
+		if passed {
+			similarity = 0.95
+		}
+		return models.LoginAttempt{
+			Similarity: similarity,
+			Threshold:  0.88,
+			Passed:     passed,
+			Timestamp:  start.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	opts := CalibrationOptions{InferWindow: 10 * time.Minute, DefaultThreshold: 0.88}
+
+	attempts := []models.LoginAttempt{
+		attempt(0, false),  // typo, then success 2 minutes later: genuine
+		attempt(2, true),   // accepted, but nothing follows: not evidence of anything
+		attempt(30, true),  // accepted, then accepted again: genuine
+		attempt(35, true),  // the user's last login in the window: unknown
+		attempt(60, false), // success only after the window: impostor
+		attempt(75, true),
+	}
+	want := []string{models.LabelGenuine, "", models.LabelGenuine, "", models.LabelImpostor, ""}
+
+	for i, w := range want {
+		if got := inferLabel(attempts, i, opts); got != w {
+			t.Errorf("attempt %d: label = %q, want %q", i, got, w)
+		}
+	}
+}
+
+func TestComputeCalibration(t *testing.T) {
+	var scores []LabeledScore
+	for _, s := range []float64{0.90, 0.92, 0.94, 0.96} {
+		scores = append(scores, LabeledScore{Score: s, Genuine: true})
+	}
+	for _, s := range []float64{0.60, 0.70, 0.80, 0.85} {
+		scores = append(scores, LabeledScore{Score: s, Genuine: false})
+	}
+
+	c := ComputeCalibration(scores, 0.01)
+	if c.Genuine != 4 || c.Impostor != 4 {
+		t.Fatalf("counts %d/%d, want 4/4", c.Genuine, c.Impostor)
+	}
+	// Perfectly separated: the first threshold above every impostor has no errors
+	if c.EER != 0 || c.EERThreshold <= 0.85 || c.EERThreshold > 0.90 {
+		t.Errorf("EER %.3f at %.2f, want 0 between 0.85 and 0.90", c.EER, c.EERThreshold)
+	}
+
+	for _, point := range c.Curve {
+		if point.FAR < 0 || point.FAR > 1 || point.FRR < 0 || point.FRR > 1 {
+			t.Errorf("rates out of range at %.2f: %+v", point.Threshold, point)
+		}
+	}
+	first, last := c.Curve[0], c.Curve[len(c.Curve)-1]
+	if first.FAR != 1 || first.FRR != 0 || last.FAR != 0 || last.FRR != 1 {
+		t.Errorf("curve ends %+v and %+v, want FAR 1 → 0 and FRR 0 → 1", first, last)
+	}
+
+	if empty := ComputeCalibration(scores[:4], 0.01); len(empty.Curve) != 0 {
+		t.Errorf("calibration without impostors produced a curve: %+v", empty)
+	}
+}
+
+// TestCalibrateSkipsUnknownAttempts checks that attempts the heuristic can't
+// label are counted as skipped and kept out of the error rates
+func TestCalibrateSkipsUnknownAttempts(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	attempt := func(username string, minutes int, similarity float64, label string) models.LoginAttempt {
+		return models.LoginAttempt{
+			Username:   username,
+			Similarity: similarity,
+			Threshold:  0.88,
+			Passed:     similarity >= 0.88,
+			Label:      label,
+			Timestamp:  start.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+	attempts := []models.LoginAttempt{
+		attempt("alice", 0, 0.80, ""),                  // genuine: alice gets in a minute later
+		attempt("alice", 1, 0.95, ""),                  // alice's last login: unknown
+		attempt("bob", 0, 0.70, ""),                    // impostor
+		attempt("bob", 30, 0.99, models.LabelImpostor), // operator label wins
+	}
+	opts := CalibrationOptions{InferLabels: true, InferWindow: 10 * time.Minute, DefaultThreshold: 0.88, PerUser: true, Step: 0.01}
+
+	report := calibrateAttempts(attempts, opts)
+	if report.Inferred != 2 || report.Skipped != 1 {
+		t.Errorf("inferred %d, skipped %d, want 2 and 1", report.Inferred, report.Skipped)
+	}
+	if report.Overall.Genuine != 1 || report.Overall.Impostor != 2 {
+		t.Errorf("overall %d genuine, %d impostor, want 1 and 2", report.Overall.Genuine, report.Overall.Impostor)
+	}
+	for _, user := range report.Users {
+		if user.Username == "alice" && user.Genuine+user.Impostor != 1 {
+			t.Errorf("alice has %d labeled attempts, want 1", user.Genuine+user.Impostor)
+		}
+	}
+
+	opts.InferLabels = false
+	if report := calibrateAttempts(attempts, opts); report.Inferred != 0 || report.Skipped != 3 {
+		t.Errorf("without inference: inferred %d, skipped %d, want 0 and 3", report.Inferred, report.Skipped)
+	}
+}
@@ -0,0 +1,210 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"semantic-auth/db"
+	"semantic-auth/models"
+	"semantic-auth/openai"
+	"semantic-auth/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DimensionOptions selects the attempts to replay and how to score them
+type DimensionOptions struct {
+	Dimensions []int
+	Threshold  float64
+	Limit      int64 // most recent attempts to replay
+
+	// Scorer and Aggregator apply to users without their own, as at login
+	Scorer     utils.Scorer
+	Aggregator utils.Aggregator
+}
+
+// DimensionResult summarizes how login decisions change when embeddings
+// are shortened to Dimensions, relative to the full stored size
+type DimensionResult struct {
+	Dimensions   int     `json:"dimensions"`
+	Attempts     int     `json:"attempts"`
+	Skipped      int     `json:"skipped"` // a stored vector shorter than Dimensions
+	Passed       int     `json:"passed"`
+	Unchanged    int     `json:"unchanged"`
+	NewlyPassed  int     `json:"newly_passed"`
+	NewlyFailed  int     `json:"newly_failed"`
+	MeanAbsDelta float64 `json:"mean_abs_delta"`
+}
+
+// DimensionReport is the comparison at every candidate dimension
+type DimensionReport struct {
+	Replayed int               `json:"replayed"`
+	Failed   int               `json:"failed"` // attempts that could not be embedded or scored
+	Results  []DimensionResult `json:"results"`
+}
+
+// CompareDimensions replays the most recent login attempts at each candidate
+// dimension. Every vector of the user and the re-embedded guess are truncated
+// and renormalized, which is what the provider's dimensions parameter does
+// for text-embedding-3 models, and scored with the user's scorer and
+// aggregator as at login. Attempts that can't be embedded, e.g. because
+// moderation now rejects them, are skipped and counted as failed.
+func CompareDimensions(ctx context.Context, opts DimensionOptions) (*DimensionReport, error) {
+	coll := db.Client.Database("semantic_auth").Collection("login_attempts")
+
+	cursor, err := coll.Find(ctx, bson.M{},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(opts.Limit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query login attempts: %w", err)
+	}
+	var attempts []models.LoginAttempt
+	if err := cursor.All(ctx, &attempts); err != nil {
+		return nil, fmt.Errorf("read login attempts: %w", err)
+	}
+
+	users, err := loadUsers(ctx, attempts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only attempts whose user still exists can be replayed
+	var replay []models.LoginAttempt
+	for _, attempt := range attempts {
+		if _, ok := users[attempt.Username]; ok {
+			replay = append(replay, attempt)
+		}
+	}
+
+	// Embed one input at a time so a single failure only loses that attempt.
+	// Repeated inputs are answered by the embedding caches.
+	guesses := make([][]float32, len(replay))
+	var lastErr error
+	for i, attempt := range replay {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		guesses[i], err = openai.Embed(ctx, attempt.Input)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping attempt that could not be embedded", "username", attempt.Username, "attempt", attempt.ID.Hex(), "error", err)
+			lastErr = err
+		}
+	}
+
+	report := compareAttempts(replay, users, guesses, opts)
+	if report.Replayed == 0 && lastErr != nil {
+		return nil, fmt.Errorf("embed attempts: %w", lastErr)
+	}
+	return report, nil
+}
+
+// compareAttempts scores each attempt at its full size and at every candidate
+// dimension. guesses is aligned with attempts and nil where embedding failed.
+func compareAttempts(attempts []models.LoginAttempt, users map[string]models.User, guesses [][]float32, opts DimensionOptions) *DimensionReport {
+	report := &DimensionReport{Results: make([]DimensionResult, len(opts.Dimensions))}
+	for i, d := range opts.Dimensions {
+		report.Results[i].Dimensions = d
+	}
+
+	for i, attempt := range attempts {
+		user := users[attempt.Username]
+		guess := guesses[i]
+		if guess == nil {
+			report.Failed++
+			continue
+		}
+
+		// Unknown names fall back to the defaults, as at login
+		scorer, aggregator, _ := utils.ScoringFor(user.Scorer, user.Aggregator, opts.Scorer, opts.Aggregator)
+		stored := user.AllVectors()
+		baseline, err := utils.ScoreMulti(scorer, aggregator, stored, guess)
+		if err != nil {
+			report.Failed++
+			continue
+		}
+		report.Replayed++
+
+		shortest := len(guess)
+		for _, vector := range stored {
+			shortest = min(shortest, len(vector))
+		}
+
+		for j, d := range opts.Dimensions {
+			result := &report.Results[j]
+			if d > shortest {
+				result.Skipped++
+				continue
+			}
+
+			truncated := make([][]float32, len(stored))
+			for k, vector := range stored {
+				truncated[k] = utils.Truncate(vector, d)
+			}
+			similarity, err := utils.ScoreMulti(scorer, aggregator, truncated, utils.Truncate(guess, d))
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+
+			result.Attempts++
+			result.MeanAbsDelta += math.Abs(similarity - baseline)
+
+			passed := similarity >= opts.Threshold
+			wasPassed := baseline >= opts.Threshold
+			if passed {
+				result.Passed++
+			}
+			switch {
+			case passed == wasPassed:
+				result.Unchanged++
+			case passed:
+				result.NewlyPassed++
+			default:
+				result.NewlyFailed++
+			}
+		}
+	}
+
+	for i := range report.Results {
+		if report.Results[i].Attempts > 0 {
+			report.Results[i].MeanAbsDelta /= float64(report.Results[i].Attempts)
+		}
+	}
+
+	return report
+}
+
+// loadUsers fetches the users referenced by a set of attempts, keyed by username
+func loadUsers(ctx context.Context, attempts []models.LoginAttempt) (map[string]models.User, error) {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, attempt := range attempts {
+		if !seen[attempt.Username] {
+			seen[attempt.Username] = true
+			usernames = append(usernames, attempt.Username)
+		}
+	}
+
+	users := make(map[string]models.User, len(usernames))
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	cursor, err := db.Client.Database("semantic_auth").Collection("users").
+		Find(ctx, bson.M{"username": bson.M{"$in": usernames}})
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	var docs []models.User
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("read users: %w", err)
+	}
+
+	for _, user := range docs {
+		users[user.Username] = user
+	}
+	return users, nil
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"math/rand"
+	"testing"
+
+	"semantic-auth/internal/testvec"
+	"semantic-auth/models"
+	"semantic-auth/utils"
+)
+
+func TestCompareAttempts(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+	guess := testvec.EmbeddingLike(rng, 1536, 8)
+	near := models.Vector(testvec.Float32(testvec.NearDuplicate(rng, guess, 0.97)))
+	far := models.Vector(testvec.Float32(testvec.NearDuplicate(rng, guess, 0.3)))
+	short := models.Vector(utils.Truncate(near, 256))
+
+	users := map[string]models.User{
+		// Only the additional phrase matches, so scoring just the primary vector fails
+		"second": {Username: "second", Vector: far, Vectors: []models.Vector{near}},
+		// The same phrases averaged with the user's own aggregator fail
+		"mean": {Username: "mean", Vector: far, Vectors: []models.Vector{near}, Aggregator: "mean"},
+		// Enrolled at 256 dimensions, so it can't be replayed at 512
+		"short": {Username: "short", Vector: short},
+		// An unknown scorer falls back to the default, as at login
+		"invalid": {Username: "invalid", Vector: near, Scorer: "hamming"},
+	}
+	attempts := []models.LoginAttempt{
+		{Username: "second"},
+		{Username: "mean"},
+		{Username: "short"},
+		{Username: "invalid"},
+		{Username: "second"}, // embedding failed
+	}
+	g := testvec.Float32(guess)
+	guesses := [][]float32{g, g, g, g, nil}
+
+	report := compareAttempts(attempts, users, guesses, DimensionOptions{
+		Dimensions: []int{512, 256},
+		Threshold:  0.88,
+		Scorer:     utils.CosineScorer{},
+		Aggregator: utils.MaxAggregator{},
+	})
+
+	if report.Replayed != 4 || report.Failed != 1 {
+		t.Errorf("replayed %d, failed %d, want 4 and 1", report.Replayed, report.Failed)
+	}
+
+	at512, at256 := report.Results[0], report.Results[1]
+	if at512.Attempts != 3 || at512.Skipped != 1 {
+		t.Errorf("512: %d attempts, %d skipped, want 3 and the 256-dimension user skipped", at512.Attempts, at512.Skipped)
+	}
+	if at256.Attempts != 4 || at256.Skipped != 0 {
+		t.Errorf("256: %d attempts, %d skipped, want 4 and none skipped", at256.Attempts, at256.Skipped)
+	}
+	// second and invalid pass on their closest phrase, mean averages below the threshold
+	if at512.Passed != 2 || at256.Passed != 3 {
+		t.Errorf("passed %d at 512 and %d at 256, want 2 and 3", at512.Passed, at256.Passed)
+	}
+	for _, result := range report.Results {
+		if result.Unchanged+result.NewlyPassed+result.NewlyFailed != result.Attempts {
+			t.Errorf("%d: decisions do not add up: %+v", result.Dimensions, result)
+		}
+		if result.MeanAbsDelta > 0.05 {
+			t.Errorf("%d: mean delta %v, truncation should barely move these scores", result.Dimensions, result.MeanAbsDelta)
+		}
+	}
+}
+
+func TestCompareAttemptsCountsFailures(t *testing.T) {
+	users := map[string]models.User{"steve": {Username: "steve", Vector: models.Vector{1, 0}}}
+	report := compareAttempts(
+		[]models.LoginAttempt{{Username: "steve"}, {Username: "steve"}},
+		users,
+		[][]float32{nil, {1, 0}},
+		DimensionOptions{Dimensions: []int{2}, Threshold: 0.88, Scorer: utils.CosineScorer{}, Aggregator: utils.MaxAggregator{}},
+	)
+	if report.Failed != 1 || report.Replayed != 1 || report.Results[0].Attempts != 1 {
+		t.Errorf("report %+v", report)
+	}
+}
@@ -0,0 +1,50 @@
+// Package api holds the JSON types of the HTTP API that clients share with
+// the server: the response envelope, error codes and the request and
+// response bodies of the public endpoints. It depends only on the standard
+// library, so services can talk to semanticAuth without importing the server.
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Prefix is where the versioned API is mounted
+const Prefix = "/v1"
+
+// StandardResponse is a consistent response structure for all API endpoints
+type StandardResponse struct {
+	Status  string      `json:"status"`            // "success" or "error"
+	Success bool        `json:"success"`           // true or false
+	Message string      `json:"message,omitempty"` // optional message
+	Code    string      `json:"code,omitempty"`    // machine-readable error code
+	Data    interface{} `json:"data,omitempty"`    // payload data
+}
+
+// Error codes returned in StandardResponse.Code. Handlers use a specific code
+// where clients need to tell failures apart, and the code for the status otherwise.
+const (
+	CodeInvalidRequest     = "invalid_request"
+	CodeUnauthorized       = "unauthorized"
+	CodeForbidden          = "forbidden"
+	CodeNotFound           = "not_found"
+	CodeConflict           = "conflict"
+	CodeInternal           = "internal_error"
+	CodeUnavailable        = "unavailable"
+	CodeUserExists         = "user_exists"
+	CodeUserNotFound       = "user_not_found"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeAccountLocked      = "account_locked"
+	CodeContentRejected    = "content_rejected"
+	CodeInvalidSession     = "invalid_session"
+	CodeReauthRequired     = "reauthentication_required"
+)
+
+// BearerToken returns the token of an "Authorization: Bearer" header, or ""
+func BearerToken(r *http.Request) string {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,92 @@
+package api
+
+import "time"
+
+type LoginRequest struct {
+	Username  string  `json:"username"`
+	Password  string  `json:"password"`
+	Threshold float64 `json:"threshold"` // optional, may only raise the configured threshold
+}
+
+// LoginResponse is the data of a successful login, including the session
+// token to send as "Authorization: Bearer <token>"
+type LoginResponse struct {
+	Username   string    `json:"username"`
+	Similarity float64   `json:"similarity"`
+	Threshold  float64   `json:"threshold"`
+	Scorer     string    `json:"scorer"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type RegisterRequest struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Phrases  []string `json:"phrases,omitempty"` // optional additional phrases
+}
+
+// RegisterResponse is the data of a successful registration
+type RegisterResponse struct {
+	Username string `json:"username"`
+}
+
+type IntrospectRequest struct {
+	Token string `json:"token"`
+}
+
+// SessionResponse describes a live session
+type SessionResponse struct {
+	Username        string    `json:"username"`
+	AuthenticatedAt time.Time `json:"authenticated_at"` // when the login succeeded
+	ExpiresAt       time.Time `json:"expires_at"`
+	Similarity      float64   `json:"similarity"` // score of the login that created the session
+}
+
+type ReportResponse struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	Input      string    `json:"input"`
+	Similarity float64   `json:"similarity"`
+	Timestamp  time.Time `json:"timestamp"`
+	Passed     bool      `json:"passed"`
+}
+
+// ReportPage is one page of login attempts
+type ReportPage struct {
+	Attempts   []ReportResponse `json:"attempts"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Total      int64            `json:"total"` // attempts matching the filters across all pages
+}
+
+type StatsCounts struct {
+	Username       string  `json:"username,omitempty"`
+	Attempts       int64   `json:"attempts"`
+	Passed         int64   `json:"passed"`
+	PassRate       float64 `json:"pass_rate"`
+	MeanSimilarity float64 `json:"mean_similarity"`
+}
+
+type HistogramBin struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Count int64   `json:"count"`
+}
+
+type SeriesPoint struct {
+	Start    time.Time `json:"start"`
+	Attempts int64     `json:"attempts"`
+	Passed   int64     `json:"passed"`
+	PassRate float64   `json:"pass_rate"`
+}
+
+type StatsResponse struct {
+	From      time.Time      `json:"from"`
+	To        time.Time      `json:"to"`
+	Threshold float64        `json:"threshold"`
+	BinWidth  float64        `json:"bin_width"`
+	Bucket    string         `json:"bucket"`
+	Global    StatsCounts    `json:"global"`
+	Users     []StatsCounts  `json:"users"`
+	Histogram []HistogramBin `json:"histogram"`
+	Series    []SeriesPoint  `json:"series"`
+}
@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// Backend is an embedding cache that can sit between Embed and OpenAI.
+// Both the semantic cache service and Redis implement it.
+type Backend interface {
+	// GetEmbedding returns the cached vector for a normalized input, or an
+	// error if there is no usable entry
+	GetEmbedding(ctx context.Context, input string, mode LookupMode) ([]float32, error)
+
+	// StoreEmbedding caches a vector on a best-effort basis
+	StoreEmbedding(ctx context.Context, input string, vector []float32)
+
+	// GetEmbeddings looks up several inputs at once. The result is aligned
+	// with inputs and holds nil for every miss.
+	GetEmbeddings(ctx context.Context, inputs []string, mode LookupMode) [][]float32
+
+	// StoreEmbeddings caches several vectors on a best-effort basis
+	StoreEmbeddings(ctx context.Context, inputs []string, vectors [][]float32)
+
+	// IsEnabled returns whether the backend is enabled
+	IsEnabled() bool
+
+	// Source is the metrics label for embeddings served by the backend
+	Source() string
+
+	// HealthCheck checks if the backend is reachable
+	HealthCheck(ctx context.Context) bool
+}
+
+// checkVector rejects a cached vector of the wrong size or with values no
+// embedding has, which would otherwise poison every similarity computed with it
+func checkVector(vector []float32, dimensions int) error {
+	if len(vector) != dimensions {
+		return fmt.Errorf("cached vector has %d dimensions, expected %d", len(vector), dimensions)
+	}
+	for _, v := range vector {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("cached vector contains non-finite values")
+		}
+	}
+	return nil
+}
@@ -4,13 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
 	"time"
 
+	"semantic-auth/metrics"
 	"semantic-auth/models"
+	"semantic-auth/tracing"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Client represents a client for the semantic cache service
@@ -21,7 +25,7 @@ type Client struct {
 
 // NewClient creates a new semantic cache client
 func NewClient(config models.CacheConfig) *Client {
-	client := resty.New().
+	client := tracing.InstrumentResty(resty.New()).
 		SetTimeout(5 * time.Second).
 		SetRetryCount(1)
 
@@ -31,18 +35,45 @@ func NewClient(config models.CacheConfig) *Client {
 	}
 }
 
+// LookupMode selects how strict a cache lookup is about near matches
+type LookupMode int
+
+const (
+	// LookupSemantic accepts any hit at or above the configured similarity threshold
+	LookupSemantic LookupMode = iota
+	// LookupAuth is used on authentication paths and additionally requires an
+	// exact-text hit when ExactMatchForAuth is enabled
+	LookupAuth
+)
+
+// exactSimilarity is the lowest reported similarity treated as an exact-text hit
+const exactSimilarity = 0.9999
+
 // GetEmbedding attempts to get an embedding from the cache
 // If the cache is not enabled or fails, it returns nil and an error
 // The error should be logged but can be ignored if fallback is allowed
-func (c *Client) GetEmbedding(ctx context.Context, input string) ([]float64, error) {
+func (c *Client) GetEmbedding(ctx context.Context, input string, mode LookupMode) (vector []float32, err error) {
+	ctx, span := tracing.Start(ctx, "cache.GetEmbedding", attribute.String("cache.backend", "http"))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+		span.End()
+	}()
+
 	if !c.config.Enabled {
 		return nil, fmt.Errorf("cache is disabled")
 	}
 
+	threshold := c.config.SimilarityThreshold
+	exact := mode == LookupAuth && c.config.ExactMatchForAuth
+	if exact {
+		threshold = 1.0
+	}
+
 	req := models.CacheRequest{
-		Text:          input,
-		SourceSystem:  "semanticAuth",
-		AllowFallback: c.config.AllowFallback,
+		Text:                input,
+		SourceSystem:        "semanticAuth",
+		AllowFallback:       c.config.AllowFallback,
+		SimilarityThreshold: threshold,
 	}
 
 	resp, err := c.client.R().
@@ -52,42 +83,90 @@ func (c *Client) GetEmbedding(ctx context.Context, input string) ([]float64, err
 		Post(fmt.Sprintf("%s/cache", c.config.URL))
 
 	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceSemanticCache).Inc()
 		return nil, fmt.Errorf("cache request failed: %w", err)
 	}
 
 	if resp.StatusCode() != http.StatusOK {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceSemanticCache).Inc()
 		return nil, fmt.Errorf("cache returned non-OK status: %d - %s", resp.StatusCode(), resp.String())
 	}
 
 	var cacheResp models.CacheResponse
 	if err := json.Unmarshal(resp.Body(), &cacheResp); err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceSemanticCache).Inc()
 		return nil, fmt.Errorf("failed to parse cache response: %w", err)
 	}
 
 	if !cacheResp.Cached {
+		metrics.CacheResult(metrics.SourceSemanticCache, false)
 		return nil, fmt.Errorf("no cached embedding found")
 	}
 
-	// Parse the response string as a JSON array of float64
-	var vector []float64
-	if err := json.Unmarshal([]byte(cacheResp.Response), &vector); err != nil {
+	// Don't trust the service to have applied the threshold; a near match is
+	// the embedding of a different phrase
+	if cacheResp.Similarity == nil {
+		metrics.CacheResult(metrics.SourceSemanticCache, false)
+		return nil, fmt.Errorf("cache hit did not report a similarity")
+	}
+	similarity := *cacheResp.Similarity
+	if exact {
+		// An exact-text hit can still score a hair under 1.0 in floating point
+		if similarity < exactSimilarity {
+			metrics.CacheResult(metrics.SourceSemanticCache, false)
+			return nil, fmt.Errorf("cache hit is not an exact match (similarity %.4f)", similarity)
+		}
+	} else if similarity < threshold {
+		metrics.CacheResult(metrics.SourceSemanticCache, false)
+		return nil, fmt.Errorf("cache hit below similarity threshold (%.4f < %.4f)", similarity, threshold)
+	}
+
+	vector, err = c.parseVector(cacheResp.Response)
+	metrics.CacheResult(metrics.SourceSemanticCache, err == nil)
+	return vector, err
+}
+
+// parseVector decodes a cached response and checks it was produced by the
+// configured model at the configured dimensions. Older entries are a bare
+// JSON array with no model recorded; another model's vectors can have the
+// same length, so those are treated as misses and get replaced on the next store.
+func (c *Client) parseVector(response string) ([]float32, error) {
+	if strings.HasPrefix(strings.TrimSpace(response), "[") {
+		return nil, fmt.Errorf("cached vector does not record its model")
+	}
+
+	var cached models.CachedEmbedding
+	if err := json.Unmarshal([]byte(response), &cached); err != nil {
 		return nil, fmt.Errorf("failed to parse cached vector: %w", err)
 	}
+	if cached.Model != c.config.Model {
+		return nil, fmt.Errorf("cached vector is from model %q, expected %q", cached.Model, c.config.Model)
+	}
 
-	return vector, nil
+	if err := checkVector(cached.Vector, c.config.Dimensions); err != nil {
+		return nil, err
+	}
+	return cached.Vector, nil
 }
 
 // StoreEmbedding stores an embedding in the cache
 // This is a best-effort operation - errors are logged but not returned
-func (c *Client) StoreEmbedding(ctx context.Context, input string, vector []float64) {
+func (c *Client) StoreEmbedding(ctx context.Context, input string, vector []float32) {
 	if !c.config.Enabled {
 		return
 	}
 
-	// Convert the vector to a JSON string
-	vectorJSON, err := json.Marshal(vector)
+	ctx, span := tracing.Start(ctx, "cache.StoreEmbedding", attribute.String("cache.backend", "http"))
+	defer span.End()
+
+	// Wrap the vector with the model that produced it
+	vectorJSON, err := json.Marshal(models.CachedEmbedding{
+		Model:      c.config.Model,
+		Dimensions: len(vector),
+		Vector:     vector,
+	})
 	if err != nil {
-		log.Printf("Failed to marshal vector for cache storage: %v", err)
+		slog.ErrorContext(ctx, "Failed to marshal vector for cache storage", "error", err)
 		return
 	}
 
@@ -104,12 +183,39 @@ func (c *Client) StoreEmbedding(ctx context.Context, input string, vector []floa
 		Post(fmt.Sprintf("%s/cache", c.config.URL))
 
 	if err != nil {
-		log.Printf("Failed to store embedding in cache: %v", err)
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceSemanticCache).Inc()
+		slog.WarnContext(ctx, "Failed to store embedding in cache", "error", err)
 		return
 	}
 
 	if resp.StatusCode() != http.StatusOK {
-		log.Printf("Cache returned non-OK status when storing: %d - %s", resp.StatusCode(), resp.String())
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceSemanticCache).Inc()
+		slog.WarnContext(ctx, "Cache returned non-OK status when storing", "status", resp.StatusCode(), "body", resp.String())
+	}
+}
+
+// GetEmbeddings looks up each input in turn; the cache service has no batch API
+func (c *Client) GetEmbeddings(ctx context.Context, inputs []string, mode LookupMode) [][]float32 {
+	vectors := make([][]float32, len(inputs))
+	if !c.config.Enabled {
+		return vectors
+	}
+
+	for i, input := range inputs {
+		vector, err := c.GetEmbedding(ctx, input, mode)
+		if err != nil {
+			continue
+		}
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+// StoreEmbeddings stores each embedding in turn
+// This is a best-effort operation - errors are logged but not returned
+func (c *Client) StoreEmbeddings(ctx context.Context, inputs []string, vectors [][]float32) {
+	for i, input := range inputs {
+		c.StoreEmbedding(ctx, input, vectors[i])
 	}
 }
 
@@ -118,6 +224,11 @@ func (c *Client) IsEnabled() bool {
 	return c.config.Enabled
 }
 
+// Source returns the metrics label for embeddings served from the semantic cache service
+func (c *Client) Source() string {
+	return metrics.SourceSemanticCache
+}
+
 // HealthCheck checks if the cache service is healthy
 func (c *Client) HealthCheck(ctx context.Context) bool {
 	if !c.config.Enabled {
@@ -129,7 +240,7 @@ func (c *Client) HealthCheck(ctx context.Context) bool {
 		Get(fmt.Sprintf("%s/cache/health", c.config.URL))
 
 	if err != nil {
-		log.Printf("Cache health check failed: %v", err)
+		slog.WarnContext(ctx, "Cache health check failed", "error", err)
 		return false
 	}
 
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"semantic-auth/metrics"
+	"semantic-auth/models"
+)
+
+// newTestClient returns a client for a semantic cache service that answers
+// every lookup with a hit carrying similarity, or no similarity when nil
+func newTestClient(t *testing.T, similarity *float64, configure func(*models.CacheConfig)) *Client {
+	t.Helper()
+	payload, err := json.Marshal(models.CachedEmbedding{Model: "test-model", Dimensions: 3, Vector: []float32{1, 2, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.CacheResponse{
+			Cached:     true,
+			Response:   string(payload),
+			Similarity: similarity,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	config := models.DefaultCacheConfig()
+	config.Enabled = true
+	config.URL = server.URL
+	config.Model = "test-model"
+	config.Dimensions = 3
+	if configure != nil {
+		configure(&config)
+	}
+	return NewClient(config)
+}
+
+func TestClientSimilarityChecks(t *testing.T) {
+	ctx := context.Background()
+	score := func(s float64) *float64 { return &s }
+
+	cases := []struct {
+		name       string
+		similarity *float64
+		mode       LookupMode
+		exact      bool
+		hit        bool
+	}{
+		// Exact-text hits often come back a rounding error under 1.0
+		{"exact text, rounded", score(0.99999994), LookupAuth, true, true},
+		{"exact text", score(1), LookupAuth, true, true},
+		{"near match on auth path", score(0.995), LookupAuth, true, false},
+		{"near match, exact matching off", score(0.95), LookupAuth, false, true},
+		{"near match, semantic lookup", score(0.95), LookupSemantic, true, true},
+		{"below threshold", score(0.80), LookupSemantic, true, false},
+		{"no similarity reported", nil, LookupAuth, true, false},
+		{"no similarity reported, semantic lookup", nil, LookupSemantic, true, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			client := newTestClient(t, c.similarity, func(config *models.CacheConfig) {
+				config.ExactMatchForAuth = c.exact
+			})
+			vector, err := client.GetEmbedding(ctx, "phrase", c.mode)
+			if c.hit && err != nil {
+				t.Errorf("hit rejected: %v", err)
+			}
+			if !c.hit && err == nil {
+				t.Errorf("hit accepted: %v", vector)
+			}
+		})
+	}
+}
+
+func TestClientRejectsOtherModels(t *testing.T) {
+	one := 1.0
+	client := newTestClient(t, &one, func(config *models.CacheConfig) { config.Model = "other-model" })
+	if _, err := client.GetEmbedding(context.Background(), "phrase", LookupAuth); err == nil {
+		t.Error("a vector cached for another model was returned")
+	}
+}
+
+func TestClientParseVector(t *testing.T) {
+	client := NewClient(models.CacheConfig{Model: "test-model", Dimensions: 3})
+	cases := []struct {
+		name     string
+		response string
+		ok       bool
+	}{
+		{"labelled", `{"model": "test-model", "dimensions": 3, "vector": [1, 2, 3]}`, true},
+		// Another model's vectors can have the same length
+		{"legacy bare array", `[1, 2, 3]`, false},
+		{"legacy bare array with whitespace", " \n[1, 2, 3]", false},
+		{"other model", `{"model": "other-model", "dimensions": 3, "vector": [1, 2, 3]}`, false},
+		{"no model", `{"dimensions": 3, "vector": [1, 2, 3]}`, false},
+		{"wrong dimensions", `{"model": "test-model", "dimensions": 2, "vector": [1, 2]}`, false},
+		{"out of float32 range", `{"model": "test-model", "dimensions": 3, "vector": [1, 2, 1e39]}`, false},
+		{"not JSON", `test-model:1,2,3`, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vector, err := client.parseVector(c.response)
+			if c.ok && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Errorf("accepted: %v", vector)
+			}
+		})
+	}
+}
+
+func TestCheckVector(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(-1))
+	cases := []struct {
+		vector []float32
+		ok     bool
+	}{
+		{[]float32{0.6, 0.8, 0}, true},
+		{[]float32{0.6, 0.8}, false},
+		{[]float32{0.6, nan, 0}, false},
+		{[]float32{inf, 0, 0}, false},
+	}
+	for _, c := range cases {
+		if err := checkVector(c.vector, 3); (err == nil) != c.ok {
+			t.Errorf("checkVector(%v) = %v", c.vector, err)
+		}
+	}
+}
+
+// TestBackendSources checks each backend labels its hits with its own source,
+// so Redis hits are not counted as semantic cache hits
+func TestBackendSources(t *testing.T) {
+	redis, _ := newTestRedis(t, nil)
+	backends := map[string]Backend{
+		metrics.SourceSemanticCache: NewClient(models.DefaultCacheConfig()),
+		metrics.SourceRedis:         redis,
+	}
+	for want, backend := range backends {
+		if got := backend.Source(); got != want {
+			t.Errorf("%T.Source() = %q, want %q", backend, got, want)
+		}
+	}
+}
@@ -1,70 +1,85 @@
 package cache
 
 import (
-	"log"
+	"context"
+	"crypto/rand"
+	"log/slog"
 	"os"
-	"strconv"
 
+	"semantic-auth/config"
+	"semantic-auth/metrics"
 	"semantic-auth/models"
 )
 
 var (
-	// DefaultClient is the default semantic cache client
-	DefaultClient *Client
-)
+	// DefaultClient is the default embedding cache backend
+	DefaultClient Backend
 
-// Initialize initializes the semantic cache client
-func Initialize() {
-	config := models.DefaultCacheConfig()
+	// DefaultLocal is the in-process LRU checked before the semantic cache
+	DefaultLocal *LocalCache
+)
 
-	// Check if cache is enabled from environment variable
-	if enabledStr := os.Getenv("SEMANTIC_CACHE_ENABLED"); enabledStr != "" {
-		enabled, err := strconv.ParseBool(enabledStr)
-		if err != nil {
-			log.Printf("Warning: Invalid SEMANTIC_CACHE_ENABLED value: %s, defaulting to %v", enabledStr, config.Enabled)
-		} else {
-			config.Enabled = enabled
-		}
+// Initialize creates the in-process cache and the configured shared cache backend
+func Initialize(cfg config.Cache) {
+	cacheConfig := models.CacheConfig{
+		Enabled:             cfg.Enabled,
+		Backend:             cfg.Backend,
+		URL:                 cfg.URL,
+		SimilarityThreshold: cfg.SimilarityThreshold,
+		AllowFallback:       cfg.AllowFallback,
+		ExactMatchForAuth:   cfg.ExactMatchForAuth,
+		Model:               models.DefaultEmbeddingModel,
+		Dimensions:          models.EmbeddingDimensions,
+		KeyPrefix:           cfg.KeyPrefix,
+		TTL:                 cfg.TTL,
+		LocalSize:           cfg.LocalSize,
+		LocalTTL:            cfg.LocalTTL,
 	}
 
-	// Get cache URL from environment variable
-	if url := os.Getenv("SEMANTIC_CACHE_URL"); url != "" {
-		config.URL = url
+	// Key the local cache with the configured key, or a per-process random key
+	secret := []byte(cfg.LocalKey)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			slog.Error("Failed to generate local cache key", "error", err)
+			os.Exit(1)
+		}
 	}
 
-	// Get similarity threshold from environment variable
-	if thresholdStr := os.Getenv("SEMANTIC_CACHE_THRESHOLD"); thresholdStr != "" {
-		threshold, err := strconv.ParseFloat(thresholdStr, 64)
-		if err != nil {
-			log.Printf("Warning: Invalid SEMANTIC_CACHE_THRESHOLD value: %s, defaulting to %v", thresholdStr, config.SimilarityThreshold)
-		} else {
-			config.SimilarityThreshold = threshold
-		}
+	local := NewLocalCache(cacheConfig.LocalSize, cacheConfig.LocalTTL, secret)
+	metrics.WatchLocalCache(func() int { return local.Stats().Entries })
+	DefaultLocal = local
+	if DefaultLocal.IsEnabled() {
+		slog.Info("Local embedding cache enabled", "size", cacheConfig.LocalSize, "ttl", cacheConfig.LocalTTL)
+	} else {
+		slog.Info("Local embedding cache is disabled")
 	}
 
-	// Get allow fallback from environment variable
-	if fallbackStr := os.Getenv("SEMANTIC_CACHE_ALLOW_FALLBACK"); fallbackStr != "" {
-		allowFallback, err := strconv.ParseBool(fallbackStr)
+	// Create the client
+	if cacheConfig.Backend == "redis" {
+		redisClient, err := NewRedisClient(cacheConfig)
 		if err != nil {
-			log.Printf("Warning: Invalid SEMANTIC_CACHE_ALLOW_FALLBACK value: %s, defaulting to %v", fallbackStr, config.AllowFallback)
+			slog.Warn("Disabling embedding cache", "error", err)
+			cacheConfig.Enabled = false
+			DefaultClient = NewClient(cacheConfig)
 		} else {
-			config.AllowFallback = allowFallback
+			DefaultClient = redisClient
 		}
+	} else {
+		DefaultClient = NewClient(cacheConfig)
 	}
 
-	// Create the client
-	DefaultClient = NewClient(config)
-
 	if DefaultClient.IsEnabled() {
-		log.Printf("Semantic cache enabled at %s", config.URL)
-		
+		// Don't log credentials embedded in the URL
+		slog.Info("Semantic cache enabled", "backend", cacheConfig.Backend, "url", config.RedactURL(cacheConfig.URL))
+
 		// Check if the cache is healthy
-		if DefaultClient.HealthCheck(nil) {
-			log.Println("Semantic cache is healthy")
+		if DefaultClient.HealthCheck(context.Background()) {
+			slog.Info("Semantic cache is healthy")
 		} else {
-			log.Println("Warning: Semantic cache is not healthy, but will continue with fallback to direct OpenAI calls")
+			slog.Warn("Semantic cache is not healthy, but will continue with fallback to direct OpenAI calls")
 		}
 	} else {
-		log.Println("Semantic cache is disabled")
+		slog.Info("Semantic cache is disabled")
 	}
 }
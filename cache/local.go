@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"semantic-auth/metrics"
+)
+
+// LocalCache is a bounded in-process LRU of embeddings, checked before any
+// network call. Entries are keyed by an HMAC of the normalized input so the
+// raw phrase is never held as a map key.
+type LocalCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	secret  []byte
+	entries map[string]*list.Element
+	order   *list.List
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type localEntry struct {
+	key     string
+	vector  []float32
+	expires time.Time
+}
+
+// LocalCacheStats is a snapshot of the local cache counters
+type LocalCacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// NewLocalCache creates an LRU holding at most size entries for ttl each.
+// A size of zero or less disables the cache; a ttl of zero never expires entries.
+func NewLocalCache(size int, ttl time.Duration, secret []byte) *LocalCache {
+	return &LocalCache{
+		size:    size,
+		ttl:     ttl,
+		secret:  secret,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// IsEnabled returns whether the local cache holds any entries at all
+func (c *LocalCache) IsEnabled() bool {
+	return c.size > 0
+}
+
+func (c *LocalCache) key(input string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(input))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Get returns the cached embedding for a normalized input, if present and fresh
+func (c *LocalCache) Get(input string) ([]float32, bool) {
+	if !c.IsEnabled() {
+		return nil, false
+	}
+
+	key := c.key(input)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		metrics.CacheResult(metrics.SourceLocal, false)
+		return nil, false
+	}
+
+	entry := elem.Value.(*localEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.misses.Add(1)
+		metrics.CacheResult(metrics.SourceLocal, false)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits.Add(1)
+	metrics.CacheResult(metrics.SourceLocal, true)
+	return entry.vector, true
+}
+
+// Set stores the embedding for a normalized input, evicting the least recently used entry if full
+func (c *LocalCache) Set(input string, vector []float32) {
+	if !c.IsEnabled() {
+		return
+	}
+
+	key := c.key(input)
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*localEntry)
+		entry.vector = vector
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&localEntry{key: key, vector: vector, expires: expires})
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*localEntry).key)
+	}
+}
+
+// Stats returns the current hit/miss counters and entry count
+func (c *LocalCache) Stats() LocalCacheStats {
+	c.mu.Lock()
+	entries := c.order.Len()
+	c.mu.Unlock()
+
+	return LocalCacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: entries,
+	}
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocalCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLocalCache(3, 0, []byte("key"))
+	c.Set("a", []float32{1})
+	c.Set("b", []float32{2})
+	c.Set("c", []float32{3})
+
+	// Reading a and rewriting b leave c as the least recently used
+	c.Get("a")
+	c.Set("b", []float32{20})
+	c.Set("d", []float32{4})
+
+	for _, want := range []struct {
+		input  string
+		vector float32
+	}{{"a", 1}, {"b", 20}, {"d", 4}} {
+		if vector, ok := c.Get(want.input); !ok || vector[0] != want.vector {
+			t.Errorf("Get(%q) = %v, %v, want [%v]", want.input, vector, ok, want.vector)
+		}
+	}
+	if _, ok := c.Get("c"); ok {
+		t.Error("c was not evicted")
+	}
+
+	// a, read first above, is now the oldest
+	c.Set("e", []float32{5})
+	if _, ok := c.Get("a"); ok {
+		t.Error("a was not evicted after b and d were used")
+	}
+	if got := c.Stats().Entries; got != 3 {
+		t.Errorf("%d entries, want the size of 3", got)
+	}
+}
+
+func TestLocalCacheExpiry(t *testing.T) {
+	c := NewLocalCache(10, 50*time.Millisecond, []byte("key"))
+	c.Set("old", []float32{1})
+	time.Sleep(60 * time.Millisecond)
+	c.Set("new", []float32{2})
+
+	if _, ok := c.Get("old"); ok {
+		t.Error("expired entry was returned")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("fresh entry was not returned")
+	}
+	if got := c.Stats().Entries; got != 1 {
+		t.Errorf("%d entries, want the expired one removed", got)
+	}
+
+	// Setting an entry again restarts its time to live
+	c.Set("new", []float32{3})
+	time.Sleep(30 * time.Millisecond)
+	c.Set("new", []float32{4})
+	time.Sleep(30 * time.Millisecond)
+	if vector, ok := c.Get("new"); !ok || vector[0] != 4 {
+		t.Errorf("Get(new) = %v, %v, want the refreshed entry", vector, ok)
+	}
+}
+
+func TestLocalCacheStats(t *testing.T) {
+	c := NewLocalCache(2, time.Millisecond, []byte("key"))
+	c.Set("a", []float32{1})
+	c.Get("a")
+	time.Sleep(5 * time.Millisecond)
+	c.Get("a") // expired
+	c.Get("b") // never set
+	c.Set("b", []float32{2})
+	c.Get("b")
+
+	want := LocalCacheStats{Hits: 2, Misses: 2, Entries: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestLocalCacheDisabled(t *testing.T) {
+	c := NewLocalCache(0, time.Minute, []byte("key"))
+	c.Set("a", []float32{1})
+	if _, ok := c.Get("a"); ok || c.IsEnabled() {
+		t.Error("a cache of size 0 stored an entry")
+	}
+	if got := c.Stats(); got != (LocalCacheStats{}) {
+		t.Errorf("Stats() = %+v, want nothing counted", got)
+	}
+}
+
+func TestLocalCacheKeys(t *testing.T) {
+	c := NewLocalCache(10, 0, []byte("key"))
+	other := NewLocalCache(10, 0, []byte("other key"))
+
+	// Keys are MACs, not the phrase
+	if c.key("open sesame") == "open sesame" || c.key("open sesame") == other.key("open sesame") {
+		t.Error("keys do not depend on the secret")
+	}
+	c.Set("open sesame", []float32{1})
+	if _, ok := c.entries["open sesame"]; ok {
+		t.Error("the raw phrase is a map key")
+	}
+}
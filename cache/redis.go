@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"semantic-auth/metrics"
+	"semantic-auth/models"
+	"semantic-auth/tracing"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RedisClient is an exact-match embedding cache backed by Redis.
+// Vectors are stored as little-endian float32 under a key prefixed with the
+// embedding model and dimensions, so a model change never serves stale vectors.
+type RedisClient struct {
+	config models.CacheConfig
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisClient creates a Redis cache client from a redis:// URL in config.URL
+func NewRedisClient(config models.CacheConfig) (*RedisClient, error) {
+	opts, err := redis.ParseURL(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+
+	return &RedisClient{
+		config: config,
+		client: redis.NewClient(opts),
+		prefix: fmt.Sprintf("%s:%s:%d:", config.KeyPrefix, config.Model, config.Dimensions),
+	}, nil
+}
+
+func (c *RedisClient) key(input string) string {
+	return fmt.Sprintf("%s%x", c.prefix, sha256.Sum256([]byte(input)))
+}
+
+// GetEmbedding looks up the exact input text. Redis never returns near
+// matches, so every lookup mode behaves the same.
+func (c *RedisClient) GetEmbedding(ctx context.Context, input string, mode LookupMode) (vector []float32, err error) {
+	if !c.config.Enabled {
+		return nil, fmt.Errorf("cache is disabled")
+	}
+
+	ctx, span := tracing.Start(ctx, "cache.GetEmbedding", attribute.String("cache.backend", "redis"))
+	defer func() {
+		span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+		span.End()
+	}()
+
+	data, err := c.client.Get(ctx, c.key(input)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		metrics.CacheResult(metrics.SourceRedis, false)
+		return nil, fmt.Errorf("no cached embedding found")
+	}
+	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceRedis).Inc()
+		return nil, fmt.Errorf("cache request failed: %w", err)
+	}
+
+	vector, err = decodeFloat32(data)
+	if err == nil {
+		err = checkVector(vector, c.config.Dimensions)
+	}
+	if err != nil {
+		metrics.CacheResult(metrics.SourceRedis, false)
+		return nil, err
+	}
+
+	metrics.CacheResult(metrics.SourceRedis, true)
+	return vector, nil
+}
+
+// StoreEmbedding stores an embedding in Redis with the configured TTL
+// This is a best-effort operation - errors are logged but not returned
+func (c *RedisClient) StoreEmbedding(ctx context.Context, input string, vector []float32) {
+	if !c.config.Enabled {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "cache.StoreEmbedding", attribute.String("cache.backend", "redis"))
+	defer span.End()
+
+	if err := c.client.Set(ctx, c.key(input), encodeFloat32(vector), c.config.TTL).Err(); err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceRedis).Inc()
+		slog.WarnContext(ctx, "Failed to store embedding in Redis", "error", err)
+	}
+}
+
+// GetEmbeddings fetches several inputs with a single MGET
+func (c *RedisClient) GetEmbeddings(ctx context.Context, inputs []string, mode LookupMode) [][]float32 {
+	vectors := make([][]float32, len(inputs))
+	if !c.config.Enabled || len(inputs) == 0 {
+		return vectors
+	}
+
+	ctx, span := tracing.Start(ctx, "cache.GetEmbeddings",
+		attribute.String("cache.backend", "redis"), attribute.Int("cache.inputs", len(inputs)))
+	defer span.End()
+
+	keys := make([]string, len(inputs))
+	for i, input := range inputs {
+		keys[i] = c.key(input)
+	}
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceRedis).Inc()
+		slog.WarnContext(ctx, "Redis batch lookup failed", "error", err)
+		return vectors
+	}
+
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			metrics.CacheResult(metrics.SourceRedis, false)
+			continue
+		}
+		vector, err := decodeFloat32([]byte(data))
+		if err == nil {
+			err = checkVector(vector, c.config.Dimensions)
+		}
+		if err != nil {
+			metrics.CacheResult(metrics.SourceRedis, false)
+			continue
+		}
+		metrics.CacheResult(metrics.SourceRedis, true)
+		vectors[i] = vector
+	}
+	return vectors
+}
+
+// StoreEmbeddings writes several embeddings in one pipeline
+// This is a best-effort operation - errors are logged but not returned
+func (c *RedisClient) StoreEmbeddings(ctx context.Context, inputs []string, vectors [][]float32) {
+	if !c.config.Enabled || len(inputs) == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "cache.StoreEmbeddings",
+		attribute.String("cache.backend", "redis"), attribute.Int("cache.inputs", len(inputs)))
+	defer span.End()
+
+	pipe := c.client.Pipeline()
+	for i, input := range inputs {
+		pipe.Set(ctx, c.key(input), encodeFloat32(vectors[i]), c.config.TTL)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceRedis).Inc()
+		slog.WarnContext(ctx, "Failed to store embeddings in Redis", "error", err)
+	}
+}
+
+// IsEnabled returns whether the cache is enabled
+func (c *RedisClient) IsEnabled() bool {
+	return c.config.Enabled
+}
+
+// Source returns the metrics label for embeddings served from Redis
+func (c *RedisClient) Source() string {
+	return metrics.SourceRedis
+}
+
+// HealthCheck pings Redis
+func (c *RedisClient) HealthCheck(ctx context.Context) bool {
+	if !c.config.Enabled {
+		return false
+	}
+
+	if err := c.client.Ping(ctx).Err(); err != nil {
+		slog.WarnContext(ctx, "Redis health check failed", "error", err)
+		return false
+	}
+
+	return true
+}
+
+// encodeFloat32 packs a vector as little-endian float32 values
+func encodeFloat32(vector []float32) []byte {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// decodeFloat32 unpacks a vector written by encodeFloat32
+func decodeFloat32(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("cached vector has invalid length %d", len(data))
+	}
+
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return vector, nil
+}
@@ -0,0 +1,209 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"semantic-auth/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedis returns a Redis cache backed by an in-process miniredis
+func newTestRedis(t *testing.T, configure func(*models.CacheConfig)) (*RedisClient, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+
+	config := models.DefaultCacheConfig()
+	config.Enabled = true
+	config.Backend = "redis"
+	config.URL = "redis://" + server.Addr()
+	config.Model = "test-model"
+	config.Dimensions = 3
+	config.KeyPrefix = "test:emb"
+	config.TTL = time.Hour
+	if configure != nil {
+		configure(&config)
+	}
+
+	client, err := NewRedisClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.client.Close() })
+	return client, server
+}
+
+func TestRedisStoreAndGet(t *testing.T) {
+	ctx := context.Background()
+	client, server := newTestRedis(t, nil)
+
+	if _, err := client.GetEmbedding(ctx, "lasagna recipe", LookupAuth); err == nil {
+		t.Fatal("empty cache returned a vector")
+	}
+
+	vector := []float32{0.25, -0.5, 1}
+	client.StoreEmbedding(ctx, "lasagna recipe", vector)
+
+	got, err := client.GetEmbedding(ctx, "lasagna recipe", LookupAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, vector) {
+		t.Errorf("got %v, want %v", got, vector)
+	}
+
+	// Exact match only: any other text misses
+	if _, err := client.GetEmbedding(ctx, "lasagna recipes", LookupSemantic); err == nil {
+		t.Error("a different input hit the cache")
+	}
+
+	// Stored under the model-and-dimension prefix, as 4 bytes per component
+	key := fmt.Sprintf("test:emb:test-model:3:%x", sha256.Sum256([]byte("lasagna recipe")))
+	data, err := server.Get(key)
+	if err != nil {
+		t.Fatalf("vector not stored under %s: %v (keys %v)", key, err, server.Keys())
+	}
+	if len(data) != 12 {
+		t.Errorf("stored %d bytes, want 12", len(data))
+	}
+}
+
+func TestRedisTTL(t *testing.T) {
+	ctx := context.Background()
+	client, server := newTestRedis(t, func(c *models.CacheConfig) { c.TTL = time.Minute })
+
+	client.StoreEmbedding(ctx, "a", []float32{1, 2, 3})
+	client.StoreEmbeddings(ctx, []string{"b"}, [][]float32{{4, 5, 6}})
+
+	for _, key := range server.Keys() {
+		if ttl := server.TTL(key); ttl != time.Minute {
+			t.Errorf("%s has TTL %v, want 1m", key, ttl)
+		}
+	}
+
+	server.FastForward(2 * time.Minute)
+	if _, err := client.GetEmbedding(ctx, "a", LookupAuth); err == nil {
+		t.Error("expired vector was returned")
+	}
+	if got := client.GetEmbeddings(ctx, []string{"b"}, LookupAuth); got[0] != nil {
+		t.Error("expired vector was returned from a batch")
+	}
+}
+
+// TestRedisKeyPrefixIsolatesModels checks that vectors cached for one model
+// or dimension are never served to another
+func TestRedisKeyPrefixIsolatesModels(t *testing.T) {
+	ctx := context.Background()
+	small, server := newTestRedis(t, nil)
+	small.StoreEmbedding(ctx, "phrase", []float32{1, 2, 3})
+
+	other, err := NewRedisClient(func() models.CacheConfig {
+		c := small.config
+		c.Model = "other-model"
+		return c
+	}())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.client.Close()
+
+	if _, err := other.GetEmbedding(ctx, "phrase", LookupAuth); err == nil {
+		t.Error("a vector cached for another model was returned")
+	}
+	for _, key := range server.Keys() {
+		if !strings.HasPrefix(key, "test:emb:test-model:3:") {
+			t.Errorf("unexpected key %s", key)
+		}
+	}
+}
+
+func TestRedisRejectsWrongDimensions(t *testing.T) {
+	ctx := context.Background()
+	client, server := newTestRedis(t, nil)
+
+	// A vector of the wrong size under the right key, e.g. written by a
+	// misconfigured replica
+	client.StoreEmbedding(ctx, "phrase", []float32{1, 2})
+	if _, err := client.GetEmbedding(ctx, "phrase", LookupAuth); err == nil {
+		t.Error("a 2-dimension vector was returned for a 3-dimension model")
+	}
+
+	// Garbage that isn't a whole number of float32s
+	server.Set(client.key("garbage"), "abcde")
+	if _, err := client.GetEmbedding(ctx, "garbage", LookupAuth); err == nil {
+		t.Error("a corrupt vector was returned")
+	}
+	// Values no embedding has
+	client.StoreEmbedding(ctx, "nan", []float32{float32(math.NaN()), 0, 0})
+	client.StoreEmbedding(ctx, "inf", []float32{0, float32(math.Inf(1)), 0})
+	for _, input := range []string{"nan", "inf"} {
+		if vector, err := client.GetEmbedding(ctx, input, LookupAuth); err == nil {
+			t.Errorf("a non-finite vector was returned: %v", vector)
+		}
+	}
+
+	if got := client.GetEmbeddings(ctx, []string{"phrase", "garbage", "nan", "inf"}, LookupAuth); got[0] != nil || got[1] != nil || got[2] != nil || got[3] != nil {
+		t.Errorf("batch returned invalid vectors: %v", got)
+	}
+}
+
+func TestRedisBatch(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestRedis(t, nil)
+
+	inputs := []string{"one", "two", "three"}
+	vectors := [][]float32{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	client.StoreEmbeddings(ctx, inputs[:2], vectors[:2])
+
+	got := client.GetEmbeddings(ctx, inputs, LookupAuth)
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3", len(got))
+	}
+	if !reflect.DeepEqual(got[0], vectors[0]) || !reflect.DeepEqual(got[1], vectors[1]) {
+		t.Errorf("hits = %v, want %v", got[:2], vectors[:2])
+	}
+	if got[2] != nil {
+		t.Errorf("miss returned %v", got[2])
+	}
+
+	if got := client.GetEmbeddings(ctx, nil, LookupAuth); len(got) != 0 {
+		t.Errorf("empty batch returned %v", got)
+	}
+}
+
+func TestRedisDisabledAndUnavailable(t *testing.T) {
+	ctx := context.Background()
+
+	disabled, server := newTestRedis(t, func(c *models.CacheConfig) { c.Enabled = false })
+	disabled.StoreEmbedding(ctx, "phrase", []float32{1, 2, 3})
+	if len(server.Keys()) != 0 {
+		t.Error("a disabled cache stored a vector")
+	}
+	if _, err := disabled.GetEmbedding(ctx, "phrase", LookupAuth); err == nil {
+		t.Error("a disabled cache returned a vector")
+	}
+	if disabled.HealthCheck(ctx) {
+		t.Error("a disabled cache reported healthy")
+	}
+
+	client, server := newTestRedis(t, nil)
+	if !client.HealthCheck(ctx) {
+		t.Error("health check failed against a running server")
+	}
+	server.Close()
+	if client.HealthCheck(ctx) {
+		t.Error("health check passed with Redis down")
+	}
+	if _, err := client.GetEmbedding(ctx, "phrase", LookupAuth); err == nil {
+		t.Error("lookup succeeded with Redis down")
+	}
+	// Writes are best effort and must not panic
+	client.StoreEmbedding(ctx, "phrase", []float32{1, 2, 3})
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"semantic-auth/api"
+)
+
+// Register creates a user. It is not retried.
+func (c *Client) Register(ctx context.Context, req api.RegisterRequest) (*api.RegisterResponse, error) {
+	var resp api.RegisterResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/register", body: req}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Login checks a phrase and returns a session token on success. It is not
+// retried, since every attempt counts towards the account lockout.
+func (c *Client) Login(ctx context.Context, req api.LoginRequest) (*api.LoginResponse, error) {
+	var resp api.LoginResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: "/login", body: req}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Introspect returns the session for token, or ErrInvalidSession if it is
+// unknown, revoked or expired
+func (c *Client) Introspect(ctx context.Context, token string) (*api.SessionResponse, error) {
+	var resp api.SessionResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/sessions/introspect",
+		body:   api.IntrospectRequest{Token: token},
+		retry:  true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Logout revokes the session for token
+func (c *Client) Logout(ctx context.Context, token string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/logout", token: token, retry: true}, nil)
+}
+
+// ReportQuery filters and pages /report. Zero values are left to the server defaults.
+type ReportQuery struct {
+	Username      string
+	Threshold     float64
+	From, To      time.Time
+	MinSimilarity *float64
+	MaxSimilarity *float64
+	Passed        *bool
+	Sort          string // "timestamp" or "similarity"
+	Order         string // "asc" or "desc"
+	Limit         int
+	Cursor        string // ReportPage.NextCursor of the previous page
+}
+
+func (q ReportQuery) values() url.Values {
+	v := url.Values{}
+	setString(v, "username", q.Username)
+	setFloat(v, "threshold", q.Threshold)
+	setTime(v, "from", q.From)
+	setTime(v, "to", q.To)
+	if q.MinSimilarity != nil {
+		v.Set("min_similarity", strconv.FormatFloat(*q.MinSimilarity, 'f', -1, 64))
+	}
+	if q.MaxSimilarity != nil {
+		v.Set("max_similarity", strconv.FormatFloat(*q.MaxSimilarity, 'f', -1, 64))
+	}
+	if q.Passed != nil {
+		v.Set("passed", strconv.FormatBool(*q.Passed))
+	}
+	setString(v, "sort", q.Sort)
+	setString(v, "order", q.Order)
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	setString(v, "cursor", q.Cursor)
+	return v
+}
+
+// Report fetches one page of login attempts. It needs WithAdminToken.
+func (c *Client) Report(ctx context.Context, q ReportQuery) (*api.ReportPage, error) {
+	var resp api.ReportPage
+	err := c.do(ctx, request{method: http.MethodGet, path: "/report", query: q.values(), token: c.adminToken, retry: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StatsQuery selects the window and grouping of /stats. Zero values are
+// left to the server defaults.
+type StatsQuery struct {
+	Username  string
+	From, To  time.Time
+	Threshold float64
+	BinWidth  float64
+	Bucket    string // "hour" or "day"
+}
+
+func (q StatsQuery) values() url.Values {
+	v := url.Values{}
+	setString(v, "username", q.Username)
+	setTime(v, "from", q.From)
+	setTime(v, "to", q.To)
+	setFloat(v, "threshold", q.Threshold)
+	setFloat(v, "bin_width", q.BinWidth)
+	setString(v, "bucket", q.Bucket)
+	return v
+}
+
+// Stats fetches aggregated login statistics. It needs WithAdminToken.
+func (c *Client) Stats(ctx context.Context, q StatsQuery) (*api.StatsResponse, error) {
+	var resp api.StatsResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/stats", query: q.values(), token: c.adminToken, retry: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func setString(v url.Values, key, value string) {
+	if value != "" {
+		v.Set(key, value)
+	}
+}
+
+func setFloat(v url.Values, key string, value float64) {
+	if value != 0 {
+		v.Set(key, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+}
+
+func setTime(v url.Values, key string, value time.Time) {
+	if !value.IsZero() {
+		v.Set(key, value.UTC().Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,180 @@
+// Package client calls a semanticAuth server from Go, using the request and
+// response types of package api. Neither imports the server, so services
+// pull in only the standard library.
+//
+//	c := client.New("https://auth.example.com")
+//	login, err := c.Login(ctx, api.LoginRequest{Username: "steve", Password: "lasagna recipe"})
+//	if errors.Is(err, client.ErrInvalidCredentials) {
+//		...
+//	}
+//
+// Read-only and idempotent calls are retried on network errors and 502, 503
+// and 504 responses; register and login are never retried, since a retry could
+// register twice or count as a second failed login.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"semantic-auth/api"
+)
+
+// Client is a semanticAuth API client. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	adminToken string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithRetries sets how many times a safe call is retried and the initial
+// backoff, which doubles after each attempt. Zero retries disables them.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// WithAdminToken sets the server's ADMIN_TOKEN, which Report and Stats need
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.adminToken = token }
+}
+
+// New creates a client for the server at baseURL, e.g. "http://localhost:8080".
+// The versioned API prefix is added to every path.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/") + api.Prefix,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retries:    3,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// request is one API call
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	token  string // bearer token, if any
+	retry  bool   // safe to send more than once
+}
+
+// do sends req and decodes the data of a successful response into out,
+// which may be nil. Failed calls return an *Error.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+
+	attempts := 1
+	if req.retry {
+		attempts += c.retries
+	}
+	backoff := c.backoff
+
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Accept", "application/json")
+		if body != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+		if req.token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+req.token)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		if apiErr, ok := err.(*Error); ok && apiErr.temporary() {
+			lastErr = err
+			continue
+		}
+		return err
+	}
+	return lastErr
+}
+
+// decodeResponse reads a StandardResponse, returning an *Error for failures
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Keep data raw until the call is known to have succeeded
+	var envelope struct {
+		api.StandardResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		// Not from the API, e.g. a proxy error page
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+
+	if resp.StatusCode >= 300 || !envelope.Success {
+		return &Error{StatusCode: resp.StatusCode, Code: envelope.Code, Message: envelope.Message}
+	}
+
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(envelope.Data, out); err != nil {
+		return fmt.Errorf("decode response data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/client"
+)
+
+// reply is one canned response from the test server
+type reply struct {
+	status int
+	body   string
+}
+
+// envelope renders a StandardResponse body
+func envelope(t *testing.T, success bool, code, message string, data interface{}) string {
+	t.Helper()
+	body, err := json.Marshal(api.StandardResponse{Success: success, Code: code, Message: message, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+// newServer answers requests with replies in turn, repeating the last one,
+// and counts the requests it received
+func newServer(t *testing.T, replies ...reply) (*client.Client, *int32, *http.Request) {
+	t.Helper()
+	var calls int32
+	last := new(http.Request)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1)) - 1
+		*last = *r.Clone(context.Background())
+		rep := replies[min(n, len(replies)-1)]
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(rep.status)
+		w.Write([]byte(rep.body))
+	}))
+	t.Cleanup(server.Close)
+
+	c := client.New(server.URL, client.WithRetries(3, time.Millisecond), client.WithAdminToken("admin-secret"))
+	return c, &calls, last
+}
+
+func TestRetriesTemporaryFailures(t *testing.T) {
+	session := api.SessionResponse{Username: "steve"}
+	c, calls, last := newServer(t,
+		reply{http.StatusServiceUnavailable, envelope(t, false, api.CodeUnavailable, "starting", nil)},
+		reply{http.StatusBadGateway, "<html>bad gateway</html>"},
+		reply{http.StatusOK, envelope(t, true, "", "", session)},
+	)
+
+	got, err := c.Introspect(context.Background(), "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "steve" {
+		t.Errorf("username = %q, want steve", got.Username)
+	}
+	if *calls != 3 {
+		t.Errorf("%d requests, want 3", *calls)
+	}
+	if last.URL.Path != api.Prefix+"/sessions/introspect" {
+		t.Errorf("path = %s", last.URL.Path)
+	}
+}
+
+func TestGivesUpAfterRetries(t *testing.T) {
+	c, calls, _ := newServer(t, reply{http.StatusGatewayTimeout, envelope(t, false, api.CodeUnavailable, "timeout", nil)})
+
+	_, err := c.Introspect(context.Background(), "token")
+	if !errors.Is(err, client.ErrUnavailable) {
+		t.Errorf("err = %v, want ErrUnavailable", err)
+	}
+	if *calls != 4 {
+		t.Errorf("%d requests, want 1 and 3 retries", *calls)
+	}
+}
+
+func TestDoesNotRetry(t *testing.T) {
+	cases := []struct {
+		name string
+		rep  reply
+		call func(c *client.Client) error
+		want error
+	}{
+		{
+			"client error",
+			reply{http.StatusUnauthorized, envelope(t, false, api.CodeInvalidSession, "session expired", nil)},
+			func(c *client.Client) error { _, err := c.Introspect(context.Background(), "token"); return err },
+			client.ErrInvalidSession,
+		},
+		{
+			"internal error",
+			reply{http.StatusInternalServerError, envelope(t, false, api.CodeInternal, "boom", nil)},
+			func(c *client.Client) error { return c.Logout(context.Background(), "token") },
+			client.ErrInternal,
+		},
+		{
+			// Each login counts towards the lockout, so even a 503 is not retried
+			"login",
+			reply{http.StatusServiceUnavailable, envelope(t, false, api.CodeUnavailable, "starting", nil)},
+			func(c *client.Client) error {
+				_, err := c.Login(context.Background(), api.LoginRequest{Username: "steve", Password: "x"})
+				return err
+			},
+			client.ErrUnavailable,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, calls, _ := newServer(t, tc.rep)
+			err := tc.call(c)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("err = %v, want %v", err, tc.want)
+			}
+			if *calls != 1 {
+				t.Errorf("%d requests, want 1", *calls)
+			}
+		})
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	c, _, last := newServer(t, reply{http.StatusUnauthorized, envelope(t, false, api.CodeUnauthorized, "Invalid admin token", nil)})
+
+	_, err := c.Report(context.Background(), client.ReportQuery{Username: "steve"})
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+	if errors.Is(err, client.ErrForbidden) {
+		t.Error("an unauthorized error matched ErrForbidden")
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Invalid admin token" {
+		t.Errorf("error = %#v", apiErr)
+	}
+
+	if got := last.Header.Get("Authorization"); got != "Bearer admin-secret" {
+		t.Errorf("Authorization = %q, want the admin token", got)
+	}
+	if got := last.URL.Query().Get("username"); got != "steve" {
+		t.Errorf("username query = %q", got)
+	}
+}
+
+func TestMalformedResponses(t *testing.T) {
+	cases := []struct {
+		name string
+		rep  reply
+	}{
+		{"not JSON", reply{http.StatusForbidden, "<html>blocked by proxy</html>"}},
+		{"success without envelope", reply{http.StatusOK, "[]"}},
+		{"data of the wrong type", reply{http.StatusOK, `{"success": true, "status": "success", "data": "steve"}`}},
+		{"success false with 200", reply{http.StatusOK, envelope(t, false, "", "exists", nil)}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _, _ := newServer(t, tc.rep)
+			_, err := c.Register(context.Background(), api.RegisterRequest{Username: "steve", Password: "x"})
+			if err == nil {
+				t.Fatal("malformed response was accepted")
+			}
+			// No API code, so no sentinel matches
+			if errors.Is(err, client.ErrUserExists) || errors.Is(err, client.ErrInternal) {
+				t.Errorf("err %v matched a sentinel", err)
+			}
+		})
+	}
+
+	c, _, _ := newServer(t, reply{http.StatusForbidden, "<html>blocked by proxy</html>"})
+	_, err := c.Register(context.Background(), api.RegisterRequest{Username: "steve", Password: "x"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden || apiErr.Code != "" || apiErr.Message != "<html>blocked by proxy</html>" {
+		t.Errorf("error = %#v", apiErr)
+	}
+}
+
+func TestCancelDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	c := client.New(server.URL, client.WithRetries(3, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.Introspect(ctx, "token")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("returned after %v, not when the context ended", elapsed)
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"semantic-auth/api"
+)
+
+// Error is a failed API call. Match a kind of failure with errors.Is and the
+// Err* values, which compare by Code.
+type Error struct {
+	StatusCode int
+	Code       string // api.Code*, empty if the response did not come from the API
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("semanticauth: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("semanticauth: %s: %s", e.Code, e.Message)
+}
+
+// Is reports whether target is an *Error with the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// temporary reports whether the call may succeed if retried
+func (e *Error) temporary() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+var (
+	ErrInvalidRequest     = &Error{Code: api.CodeInvalidRequest}
+	ErrUnauthorized       = &Error{Code: api.CodeUnauthorized}
+	ErrForbidden          = &Error{Code: api.CodeForbidden}
+	ErrNotFound           = &Error{Code: api.CodeNotFound}
+	ErrConflict           = &Error{Code: api.CodeConflict}
+	ErrInternal           = &Error{Code: api.CodeInternal}
+	ErrUnavailable        = &Error{Code: api.CodeUnavailable}
+	ErrUserExists         = &Error{Code: api.CodeUserExists}
+	ErrUserNotFound       = &Error{Code: api.CodeUserNotFound}
+	ErrInvalidCredentials = &Error{Code: api.CodeInvalidCredentials}
+	ErrAccountLocked      = &Error{Code: api.CodeAccountLocked}
+	ErrContentRejected    = &Error{Code: api.CodeContentRejected}
+	ErrInvalidSession     = &Error{Code: api.CodeInvalidSession}
+	ErrReauthRequired     = &Error{Code: api.CodeReauthRequired}
+)
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"semantic-auth/analysis"
+	"semantic-auth/config"
+	"semantic-auth/db"
+)
+
+func purgeAttempts(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("purge-attempts")
+	olderThan := fs.Duration("older-than", 0, "only attempts older than this, e.g. 2160h")
+	username := fs.String("username", "", "only this user's attempts")
+	all := fs.Bool("all", false, "delete every attempt")
+	yes := fs.Bool("yes", false, "confirm the deletion")
+	fs.Parse(args)
+
+	filter := db.AttemptFilter{Username: normalizeUsername(*username)}
+	if *olderThan > 0 {
+		filter.Before = time.Now().Add(-*olderThan)
+	}
+	if filter == (db.AttemptFilter{}) && !*all {
+		return errors.New("give -older-than, -username or -all")
+	}
+	if !*yes {
+		return errors.New("refusing to delete without -yes")
+	}
+
+	purged, err := db.PurgeAttempts(ctx, filter)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Deleted %d login attempts\n", purged)
+	return nil
+}
+
+func calibrate(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("calibrate")
+	username := fs.String("username", "", "only calibrate against this user's attempts")
+	since := fs.Duration("since", 90*24*time.Hour, "how far back to read attempts")
+	infer := fs.Bool("infer", false, "infer labels for unlabeled attempts from later successful logins")
+	window := fs.Duration("window", 10*time.Minute, "how soon a successful login must follow for inference")
+	perUser := fs.Bool("per-user", false, "also calibrate each user separately")
+	step := fs.Float64("step", 0.01, "threshold spacing on the ROC curve")
+	fs.Parse(args)
+
+	report, err := analysis.Calibrate(ctx, analysis.CalibrationOptions{
+		Username:         normalizeUsername(*username),
+		From:             time.Now().Add(-*since),
+		InferLabels:      *infer,
+		InferWindow:      *window,
+		DefaultThreshold: cfg.Scoring.Threshold,
+		PerUser:          *perUser,
+		Step:             *step,
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tGENUINE\tIMPOSTOR\tEER THRESHOLD\tEER")
+	rows := append([]analysis.Calibration{report.Overall}, report.Users...)
+	for _, c := range rows {
+		name := c.Username
+		if name == "" {
+			name = "(all)"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.3f\t%.4f\n", name, c.Genuine, c.Impostor, c.EERThreshold, c.EER)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\nLabels inferred: %d, attempts skipped: %d\n", report.Inferred, report.Skipped)
+	return nil
+}
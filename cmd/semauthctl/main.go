@@ -0,0 +1,152 @@
+// Command semauthctl manages users and stored data for operators, using the
+// server's configuration, storage and embedding code.
+//
+// Usage:
+//
+//	semauthctl [-v] <command> [flags] [args]
+//
+// Run "semauthctl help" for the list of commands.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"semantic-auth/cache"
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/logging"
+	"semantic-auth/moderation"
+	"semantic-auth/openai"
+)
+
+// command is one semauthctl subcommand
+type command struct {
+	name    string
+	usage   string // arguments after the name
+	summary string
+	embeds  bool // needs the moderation service and OpenAI
+	run     func(ctx context.Context, cfg *config.Config, args []string) error
+}
+
+// commands is filled in init, since the commands' flag sets refer back to it for usage
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "list", usage: "[-prefix p] [-locked] [-limit n]", summary: "list users", run: listUsers},
+		{name: "show", usage: "<username>", summary: "show a user's enrollment and lockout state", run: showUser},
+		{name: "delete", usage: "[-attempts] -yes <username>", summary: "delete a user and end their sessions", run: deleteUser},
+		{name: "unlock", usage: "<username>", summary: "clear a user's lockout", run: unlockUser},
+		{name: "set-scoring", usage: "[-scorer s] [-aggregator a] <username>", summary: "choose a user's scorer and aggregator (empty for the defaults)", run: setScoring},
+		{name: "reset-phrase", usage: "[-phrase p]... [-keep-raw=false] <username>", summary: "replace a user's phrases and end their sessions (read from stdin, one per line, without -phrase)", embeds: true, run: resetPhrase},
+		{name: "export", usage: "[-o file] [-prefix p] [-include-raw]", summary: "export users as newline-delimited JSON", run: exportUsers},
+		{name: "import", usage: "[-i file] [-replace]", summary: "import users exported by export", run: importUsers},
+		{name: "purge-attempts", usage: "[-older-than d] [-username u] [-all] -yes", summary: "delete login attempts", run: purgeAttempts},
+		{name: "calibrate", usage: "[-username u] [-since d] [-infer] [-window d] [-per-user] [-step s]", summary: "suggest thresholds from labeled attempts", run: calibrate},
+		{name: "migrate-vectors", usage: "[-all]", summary: "rewrite stored vectors in the configured encoding", run: migrateVectors},
+		{name: "dimeval", usage: "[-dims d,d,...] [-threshold t] [-limit n]", summary: "replay recent attempts at smaller dimensions", embeds: true, run: dimEval},
+		{name: "re-embed", usage: "[-all] [-batch n] [-dry-run]", summary: "re-embed users enrolled with another model or dimensions", embeds: true, run: reEmbed},
+	}
+}
+
+func main() {
+	verbose := flag.Bool("v", false, "log informational messages")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 || flag.Arg(0) == "help" {
+		usage()
+		return
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flag.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "semauthctl: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	// Same configuration as the server: .env outside production, then CONFIG_FILE and the environment.
+	// Only commands that embed need the moderation service and OpenAI.
+	if os.Getenv("GO_ENV") != "production" {
+		godotenv.Load()
+	}
+	cfg, err := config.Load(config.Requirements{Embedding: cmd.embeds})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Logs go to stderr so exports can be written to stdout
+	if !*verbose {
+		cfg.Log.Level = "warn"
+	}
+	logging.InitializeTo(os.Stderr, cfg.Log)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	db.Connect(cfg.Mongo)
+	if cmd.embeds {
+		moderation.Initialize(cfg.Moderation)
+		openai.Initialize(cfg.OpenAI)
+		cache.Initialize(cfg.Cache)
+	}
+
+	err = cmd.run(ctx, cfg, flag.Args()[1:])
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	openai.Shutdown(shutdownCtx)
+	db.Disconnect(shutdownCtx)
+	cancel()
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "semauthctl %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: semauthctl [-v] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n  %-15s %s\n", c.name, c.summary, "", c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nFlags must come before arguments. Configuration is read like the server's.")
+}
+
+// newFlags creates the flag set for a command, with usage from its entry
+func newFlags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: semauthctl %s %s\n", c.name, c.usage)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usernameArg returns the single required username argument, normalized the
+// way the handlers store it
+func usernameArg(fs *flag.FlagSet) (string, error) {
+	if fs.NArg() != 1 {
+		return "", fmt.Errorf("expected one username, got %d arguments", fs.NArg())
+	}
+	return normalizeUsername(fs.Arg(0)), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/models"
+	"semantic-auth/openai"
+)
+
+// reEmbed re-embeds users whose vectors came from another model or at other
+// dimensions, so they are scored at full strength after a model change.
+// Only the stored primary phrase can be re-embedded: users without one, or
+// with additional phrases, are listed and need reset-phrase instead.
+func reEmbed(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("re-embed")
+	all := fs.Bool("all", false, "re-embed every user, not just outdated ones")
+	batchSize := fs.Int("batch", 100, "users embedded per request")
+	dryRun := fs.Bool("dry-run", false, "list the users that would be re-embedded without changing them")
+	fs.Parse(args)
+	if *batchSize <= 0 {
+		return fmt.Errorf("-batch must be positive")
+	}
+
+	var pending []models.User
+	var unrecoverable []string
+	err := db.EachUser(ctx, db.UserFilter{}, 0, func(u models.User) error {
+		current := u.Model == models.DefaultEmbeddingModel && u.Dimensions == models.EmbeddingDimensions
+		if current && !*all {
+			return nil
+		}
+		if u.Raw == "" || len(u.Vectors) > 0 {
+			unrecoverable = append(unrecoverable, u.Username)
+			return nil
+		}
+		pending = append(pending, models.User{Username: u.Username, Hash: u.Hash, Raw: u.Raw})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		for _, u := range pending {
+			fmt.Println(u.Username)
+		}
+	} else {
+		for start := 0; start < len(pending); start += *batchSize {
+			batch := pending[start:min(start+*batchSize, len(pending))]
+			phrases := make([]string, len(batch))
+			for i, u := range batch {
+				phrases[i] = u.Raw
+			}
+
+			vectors, err := openai.EmbedBatch(ctx, phrases)
+			if err != nil {
+				return fmt.Errorf("embed users %s to %s: %w", batch[0].Username, batch[len(batch)-1].Username, err)
+			}
+			for i, u := range batch {
+				err := db.SetPhrases(ctx, u.Username, u.Hash, u.Raw, models.DefaultEmbeddingModel, vectors[i:i+1])
+				if err != nil {
+					return fmt.Errorf("update %s: %w", u.Username, err)
+				}
+			}
+		}
+	}
+
+	verb := "Re-embedded"
+	if *dryRun {
+		verb = "Would re-embed"
+	}
+	fmt.Printf("%s %d users at %s/%d\n", verb, len(pending), models.DefaultEmbeddingModel, models.EmbeddingDimensions)
+	if len(unrecoverable) > 0 {
+		fmt.Printf("%d users need reset-phrase (no stored phrase, or additional phrases):\n", len(unrecoverable))
+		for _, username := range unrecoverable {
+			fmt.Println("  " + username)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/models"
+	"semantic-auth/utils"
+)
+
+// userRecord is one line of an export. Lockout state is not carried over.
+type userRecord struct {
+	Username   string      `json:"username"`
+	Hash       string      `json:"hash"`
+	Vector     []float32   `json:"vector"`
+	Vectors    [][]float32 `json:"vectors,omitempty"`
+	Model      string      `json:"model,omitempty"`
+	Dimensions int         `json:"dimensions,omitempty"`
+	Scorer     string      `json:"scorer,omitempty"`
+	Aggregator string      `json:"aggregator,omitempty"`
+	Raw        string      `json:"raw,omitempty"`
+}
+
+func exportUsers(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("export")
+	output := fs.String("o", "", "file to write (default stdout)")
+	prefix := fs.String("prefix", "", "only usernames starting with this")
+	includeRaw := fs.Bool("include-raw", false, "include stored primary phrase text")
+	fs.Parse(args)
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buf)
+
+	exported := 0
+	err := db.EachUser(ctx, db.UserFilter{Prefix: normalizeUsername(*prefix)}, 0, func(u models.User) error {
+		record := userRecord{
+			Username:   u.Username,
+			Hash:       u.Hash,
+			Vector:     u.Vector,
+			Model:      u.Model,
+			Dimensions: u.Dimensions,
+			Scorer:     u.Scorer,
+			Aggregator: u.Aggregator,
+		}
+		for _, v := range u.Vectors {
+			record.Vectors = append(record.Vectors, v)
+		}
+		if *includeRaw {
+			record.Raw = u.Raw
+		}
+		exported++
+		return encoder.Encode(record)
+	})
+	if err != nil {
+		return err
+	}
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d users\n", exported)
+	return nil
+}
+
+func importUsers(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("import")
+	input := fs.String("i", "", "file to read (default stdin)")
+	replace := fs.Bool("replace", false, "overwrite users that already exist instead of skipping them")
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	imported, skipped := 0, 0
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	for line := 1; ; line++ {
+		var record userRecord
+		err := decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		user, err := record.user()
+		if err != nil {
+			return fmt.Errorf("record %d: %w", line, err)
+		}
+
+		err = db.InsertUser(ctx, user, *replace)
+		if errors.Is(err, db.ErrUserExists) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("record %d (%s): %w", line, user.Username, err)
+		}
+		imported++
+	}
+
+	fmt.Printf("Imported %d users, skipped %d existing\n", imported, skipped)
+	return nil
+}
+
+// user validates the record and converts it to a stored user
+func (r userRecord) user() (models.User, error) {
+	username := normalizeUsername(r.Username)
+	if username == "" {
+		return models.User{}, errors.New("missing username")
+	}
+	if len(r.Vector) == 0 {
+		return models.User{}, fmt.Errorf("%s: missing vector", username)
+	}
+	if r.Scorer != "" {
+		if _, err := utils.ScorerByName(r.Scorer); err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", username, err)
+		}
+	}
+	if r.Aggregator != "" {
+		if _, err := utils.AggregatorByName(r.Aggregator); err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", username, err)
+		}
+	}
+
+	user := models.User{
+		Username:   username,
+		Hash:       r.Hash,
+		Vector:     r.Vector,
+		Model:      r.Model,
+		Dimensions: len(r.Vector),
+		Scorer:     r.Scorer,
+		Aggregator: r.Aggregator,
+		Raw:        r.Raw,
+	}
+	if user.Model == "" {
+		user.Model = models.DefaultEmbeddingModel
+	}
+	for _, v := range r.Vectors {
+		user.Vectors = append(user.Vectors, v)
+	}
+	return user, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/models"
+	"semantic-auth/moderation"
+	"semantic-auth/openai"
+	"semantic-auth/sessions"
+	"semantic-auth/utils"
+)
+
+// normalizeUsername matches the lowercased, trimmed usernames the handlers store
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// phraseList collects a repeatable -phrase flag
+type phraseList []string
+
+func (p *phraseList) String() string { return fmt.Sprintf("%d phrases", len(*p)) }
+
+func (p *phraseList) Set(value string) error {
+	*p = append(*p, value)
+	return nil
+}
+
+func listUsers(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("list")
+	prefix := fs.String("prefix", "", "only usernames starting with this")
+	locked := fs.Bool("locked", false, "only users locked out right now")
+	limit := fs.Int64("limit", 0, "maximum users to list (0 for all)")
+	fs.Parse(args)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "USERNAME\tPHRASES\tMODEL\tDIMS\tSCORER\tFAILED\tLOCKED UNTIL")
+	err := db.EachUser(ctx, db.UserFilter{Prefix: normalizeUsername(*prefix), Locked: *locked}, *limit, func(u models.User) error {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%d\t%s\n",
+			u.Username, len(u.AllVectors()), u.Model, u.Dimensions, orDefault(u.Scorer), u.FailedAttempts, lockedUntil(u))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Flush()
+}
+
+func showUser(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("show")
+	fs.Parse(args)
+	username, err := usernameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	u, err := db.FindUser(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Username:\t%s\n", u.Username)
+	fmt.Fprintf(tw, "Phrases:\t%d\n", len(u.AllVectors()))
+	fmt.Fprintf(tw, "Model:\t%s\n", u.Model)
+	fmt.Fprintf(tw, "Dimensions:\t%d\n", u.Dimensions)
+	fmt.Fprintf(tw, "Scorer:\t%s\n", orDefault(u.Scorer))
+	fmt.Fprintf(tw, "Aggregator:\t%s\n", orDefault(u.Aggregator))
+	fmt.Fprintf(tw, "Raw phrase stored:\t%t\n", u.Raw != "")
+	fmt.Fprintf(tw, "Failed attempts:\t%d\n", u.FailedAttempts)
+	fmt.Fprintf(tw, "Locked until:\t%s\n", lockedUntil(u))
+	return tw.Flush()
+}
+
+func deleteUser(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("delete")
+	attempts := fs.Bool("attempts", false, "also delete the user's login attempts")
+	yes := fs.Bool("yes", false, "confirm the deletion")
+	fs.Parse(args)
+	username, err := usernameArg(fs)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		return errors.New("refusing to delete without -yes")
+	}
+
+	purged, err := db.DeleteUser(ctx, username, *attempts)
+	if err != nil {
+		return err
+	}
+	if _, err := sessions.RevokeUser(ctx, username); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	fmt.Printf("Deleted %s", username)
+	if *attempts {
+		fmt.Printf(" and %d login attempts", purged)
+	}
+	fmt.Println()
+	return nil
+}
+
+func unlockUser(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("unlock")
+	fs.Parse(args)
+	username, err := usernameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if err := db.UnlockUser(ctx, username); err != nil {
+		return err
+	}
+	fmt.Printf("Unlocked %s\n", username)
+	return nil
+}
+
+// setScoring gives a user their own scorer and aggregator. Scores are mapped
+// to the cosine scale, so LOGIN_THRESHOLD applies whichever is chosen.
+func setScoring(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("set-scoring")
+	scorer := fs.String("scorer", "", "cosine, dot, euclidean or angular; empty for SIMILARITY_SCORER")
+	aggregator := fs.String("aggregator", "", "max, mean or topk:<k>; empty for SIMILARITY_AGGREGATOR")
+	fs.Parse(args)
+	username, err := usernameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if *scorer != "" {
+		if _, err := utils.ScorerByName(*scorer); err != nil {
+			return err
+		}
+	}
+	if *aggregator != "" {
+		if _, err := utils.AggregatorByName(*aggregator); err != nil {
+			return err
+		}
+	}
+
+	if err := db.SetScoring(ctx, username, *scorer, *aggregator); err != nil {
+		return err
+	}
+	fmt.Printf("%s now uses scorer %s and aggregator %s\n", username, orDefault(*scorer), orDefault(*aggregator))
+	return nil
+}
+
+// resetPhrase enrolls new phrases for an existing user, embedding them the
+// same way registration does. Phrases are read from stdin unless given as
+// flags, so they stay out of shell history.
+func resetPhrase(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("reset-phrase")
+	var phrases phraseList
+	fs.Var(&phrases, "phrase", "phrase to enroll; repeat for additional phrases, the first is primary")
+	keepRaw := fs.Bool("keep-raw", true, "store the primary phrase text, as registration does")
+	fs.Parse(args)
+	username, err := usernameArg(fs)
+	if err != nil {
+		return err
+	}
+
+	if len(phrases) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			phrases = append(phrases, scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+
+	var clean []string
+	for _, phrase := range phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			clean = append(clean, phrase)
+		}
+	}
+	if len(clean) == 0 {
+		return errors.New("no phrases given")
+	}
+
+	// Fail before spending embeddings on a user that does not exist
+	if _, err := db.FindUser(ctx, username); err != nil {
+		return err
+	}
+
+	vectors, err := openai.EmbedBatch(ctx, clean)
+	if err != nil {
+		var rejected *moderation.RejectedError
+		if errors.As(err, &rejected) {
+			return fmt.Errorf("phrase rejected by moderation: %w", err)
+		}
+		return fmt.Errorf("embed phrases: %w", err)
+	}
+
+	raw := ""
+	if *keepRaw {
+		raw = clean[0]
+	}
+	err = db.SetPhrases(ctx, username, models.HashPhrase(clean[0]), raw, models.DefaultEmbeddingModel, vectors)
+	if err != nil {
+		return err
+	}
+	// A new phrase starts with a clean slate, and signs out anyone holding the old one
+	if err := db.UnlockUser(ctx, username); err != nil {
+		return err
+	}
+	if _, err := sessions.RevokeUser(ctx, username); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	fmt.Printf("Enrolled %d phrases for %s\n", len(clean), username)
+	return nil
+}
+
+// orDefault shows an unset per-user override as the deployment default
+func orDefault(name string) string {
+	if name == "" {
+		return "(default)"
+	}
+	return name
+}
+
+// lockedUntil formats a user's lockout expiry, or "-" when not locked
+func lockedUntil(u models.User) string {
+	if !u.LockedUntil.After(time.Now()) {
+		return "-"
+	}
+	return u.LockedUntil.Local().Format(time.RFC3339)
+}
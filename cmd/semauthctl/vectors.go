@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"semantic-auth/analysis"
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/models"
+	"semantic-auth/utils"
+)
+
+func migrateVectors(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("migrate-vectors")
+	all := fs.Bool("all", false, "re-encode every vector, not just legacy float64 arrays")
+	fs.Parse(args)
+
+	migrated, err := db.MigrateVectors(ctx, *all)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %d vectors to %s\n", migrated, models.StoredVectorEncoding)
+	return nil
+}
+
+func dimEval(ctx context.Context, cfg *config.Config, args []string) error {
+	fs := newFlags("dimeval")
+	dimsStr := fs.String("dims", "1024,768,512,256", "comma-separated dimensions to evaluate")
+	threshold := fs.Float64("threshold", cfg.Scoring.Threshold, "similarity threshold for pass/fail")
+	limit := fs.Int64("limit", 1000, "number of most recent attempts to replay")
+	fs.Parse(args)
+
+	var dims []int
+	for _, part := range strings.Split(*dimsStr, ",") {
+		d, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid dimension %q", part)
+		}
+		dims = append(dims, d)
+	}
+
+	// Validated when the configuration was loaded
+	scorer, _ := utils.ScorerByName(cfg.Scoring.Scorer)
+	aggregator, _ := utils.AggregatorByName(cfg.Scoring.Aggregator)
+
+	report, err := analysis.CompareDimensions(ctx, analysis.DimensionOptions{
+		Dimensions: dims,
+		Threshold:  *threshold,
+		Limit:      *limit,
+		Scorer:     scorer,
+		Aggregator: aggregator,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replayed %d attempts; %d could not be embedded or scored\n\n", report.Replayed, report.Failed)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIMS\tATTEMPTS\tPASSED\tUNCHANGED\tNEWLY PASSED\tNEWLY FAILED\tMEAN |DELTA|\tSKIPPED")
+	for _, r := range report.Results {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\t%.4f\t%d\n",
+			r.Dimensions, r.Attempts, r.Passed, r.Unchanged, r.NewlyPassed, r.NewlyFailed, r.MeanAbsDelta, r.Skipped)
+	}
+	return tw.Flush()
+}
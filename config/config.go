@@ -0,0 +1,161 @@
+// Package config loads the service configuration from defaults, an optional
+// YAML or TOML file and environment variables, in that order of precedence.
+package config
+
+import (
+	"time"
+
+	"semantic-auth/models"
+)
+
+// Config is the complete service configuration. Each field's env tag names
+// the variable that overrides it; fields tagged secret are masked in the
+// startup summary ("url" masks only the password of a URL).
+type Config struct {
+	Server     Server     `yaml:"server" toml:"server"`
+	Log        Log        `yaml:"log" toml:"log"`
+	Tracing    Tracing    `yaml:"tracing" toml:"tracing"`
+	Mongo      Mongo      `yaml:"mongo" toml:"mongo"`
+	Moderation Moderation `yaml:"moderation" toml:"moderation"`
+	OpenAI     OpenAI     `yaml:"openai" toml:"openai"`
+	Cache      Cache      `yaml:"cache" toml:"cache"`
+	Scoring    Scoring    `yaml:"scoring" toml:"scoring"`
+	Lockout    Lockout    `yaml:"lockout" toml:"lockout"`
+	Session    Session    `yaml:"session" toml:"session"`
+	Report     Report     `yaml:"report" toml:"report"`
+	Readiness  Readiness  `yaml:"readiness" toml:"readiness"`
+	Admin      Admin      `yaml:"admin" toml:"admin"`
+}
+
+// Server configures the HTTP listener
+type Server struct {
+	Port            int           `yaml:"port" toml:"port" env:"PORT"`
+	CORSOrigins     []string      `yaml:"cors_allowed_origins" toml:"cors_allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+}
+
+// Log configures structured logging
+type Log struct {
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL"` // debug, info, warn or error
+}
+
+// Tracing configures the OTLP trace exporter; tracing is off without an endpoint
+type Tracing struct {
+	Endpoint       string `yaml:"endpoint" toml:"endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	TracesEndpoint string `yaml:"traces_endpoint" toml:"traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"`
+	ServiceName    string `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// Mongo configures the database connection
+type Mongo struct {
+	URI            string `yaml:"uri" toml:"uri" env:"MONGO_URI" secret:"url"`
+	VectorEncoding string `yaml:"vector_encoding" toml:"vector_encoding" env:"VECTOR_ENCODING"`
+}
+
+// Moderation configures the moderation service
+type Moderation struct {
+	URL          string `yaml:"url" toml:"url" env:"MODERATION_SERVICE_URL"`
+	StoreContent bool   `yaml:"store_content" toml:"store_content" env:"MODERATION_STORE_CONTENT"`
+}
+
+// OpenAI configures the embedding provider
+type OpenAI struct {
+	APIKey     string `yaml:"api_key" toml:"api_key" env:"OPENAI_KEY" secret:"true"`
+	Dimensions int    `yaml:"dimensions" toml:"dimensions" env:"EMBEDDING_DIMENSIONS"`
+}
+
+// Cache configures the in-process LRU and the shared embedding cache
+type Cache struct {
+	Enabled             bool          `yaml:"enabled" toml:"enabled" env:"SEMANTIC_CACHE_ENABLED"`
+	Backend             string        `yaml:"backend" toml:"backend" env:"SEMANTIC_CACHE_BACKEND"` // "http" or "redis"
+	URL                 string        `yaml:"url" toml:"url" env:"SEMANTIC_CACHE_URL" secret:"url"`
+	KeyPrefix           string        `yaml:"key_prefix" toml:"key_prefix" env:"SEMANTIC_CACHE_KEY_PREFIX"`
+	TTL                 time.Duration `yaml:"ttl" toml:"ttl" env:"SEMANTIC_CACHE_TTL"`
+	SimilarityThreshold float64       `yaml:"similarity_threshold" toml:"similarity_threshold" env:"SEMANTIC_CACHE_THRESHOLD"`
+	AllowFallback       bool          `yaml:"allow_fallback" toml:"allow_fallback" env:"SEMANTIC_CACHE_ALLOW_FALLBACK"`
+	ExactMatchForAuth   bool          `yaml:"exact_match_for_auth" toml:"exact_match_for_auth" env:"SEMANTIC_CACHE_EXACT_AUTH"`
+	LocalSize           int           `yaml:"local_size" toml:"local_size" env:"EMBEDDING_LRU_SIZE"`
+	LocalTTL            time.Duration `yaml:"local_ttl" toml:"local_ttl" env:"EMBEDDING_LRU_TTL"`
+	LocalKey            string        `yaml:"local_key" toml:"local_key" env:"EMBEDDING_LRU_KEY" secret:"true"`
+}
+
+// Scoring configures how login guesses are compared
+type Scoring struct {
+	Threshold  float64 `yaml:"threshold" toml:"threshold" env:"LOGIN_THRESHOLD"`
+	Scorer     string  `yaml:"scorer" toml:"scorer" env:"SIMILARITY_SCORER"`
+	Aggregator string  `yaml:"aggregator" toml:"aggregator" env:"SIMILARITY_AGGREGATOR"`
+}
+
+// Lockout configures account lockout after failed logins
+type Lockout struct {
+	Attempts int           `yaml:"attempts" toml:"attempts" env:"LOGIN_LOCKOUT_ATTEMPTS"` // 0 disables lockouts
+	Duration time.Duration `yaml:"duration" toml:"duration" env:"LOGIN_LOCKOUT_DURATION"`
+}
+
+// Session configures the sessions issued on successful logins
+type Session struct {
+	TTL time.Duration `yaml:"ttl" toml:"ttl" env:"SESSION_TTL"`
+}
+
+// Report configures the report, export and stream endpoints
+type Report struct {
+	RedactInput bool `yaml:"redact_input" toml:"redact_input" env:"REPORT_REDACT_INPUT"`
+}
+
+// Readiness configures /health/ready
+type Readiness struct {
+	Critical []string `yaml:"critical" toml:"critical" env:"READINESS_CRITICAL"`
+}
+
+// Admin configures the admin API; it is disabled without a token
+type Admin struct {
+	Token string `yaml:"token" toml:"token" env:"ADMIN_TOKEN" secret:"true"`
+}
+
+// Dependencies that /health/ready knows how to check
+var Dependencies = []string{"mongo", "moderation", "cache", "openai"}
+
+// Default returns the configuration used when nothing overrides it
+func Default() Config {
+	cache := models.DefaultCacheConfig()
+	return Config{
+		Server: Server{
+			Port:            8080,
+			CORSOrigins:     []string{"*"},
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     120 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Log:     Log{Level: "info"},
+		Tracing: Tracing{ServiceName: "semantic-auth"},
+		Mongo: Mongo{
+			URI:            "mongodb://localhost:27017",
+			VectorEncoding: models.VectorFloat32.String(),
+		},
+		OpenAI: OpenAI{Dimensions: models.DefaultEmbeddingDimensions},
+		Cache: Cache{
+			Enabled:             cache.Enabled,
+			Backend:             cache.Backend,
+			URL:                 cache.URL,
+			KeyPrefix:           cache.KeyPrefix,
+			TTL:                 cache.TTL,
+			SimilarityThreshold: cache.SimilarityThreshold,
+			AllowFallback:       cache.AllowFallback,
+			ExactMatchForAuth:   cache.ExactMatchForAuth,
+			LocalSize:           cache.LocalSize,
+			LocalTTL:            cache.LocalTTL,
+		},
+		Scoring: Scoring{
+			Threshold:  0.88,
+			Scorer:     "cosine",
+			Aggregator: "max",
+		},
+		Lockout:   Lockout{Duration: 15 * time.Minute},
+		Session:   Session{TTL: 24 * time.Hour},
+		Readiness: Readiness{Critical: []string{"mongo", "moderation", "openai"}},
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// masked replaces a set secret in the summary
+const masked = "********"
+
+// LogSummary logs the effective configuration, one group per section,
+// with secrets masked
+func (c *Config) LogSummary() {
+	v := reflect.ValueOf(c).Elem()
+	var sections []any
+	for i := 0; i < v.NumField(); i++ {
+		section := v.Field(i)
+		var attrs []any
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			attrs = append(attrs, slog.String(field.Tag.Get("yaml"), summarize(section.Field(j), field.Tag.Get("secret"))))
+		}
+		sections = append(sections, slog.Group(v.Type().Field(i).Tag.Get("yaml"), attrs...))
+	}
+	slog.Info("Effective configuration", sections...)
+}
+
+// summarize renders a field value for the summary, masking secrets
+func summarize(value reflect.Value, secret string) string {
+	switch secret {
+	case "true":
+		if value.String() == "" {
+			return ""
+		}
+		return masked
+	case "url":
+		return RedactURL(value.String())
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case []string:
+		return strings.Join(v, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// RedactURL hides the password in a URL or connection string. Multi-host
+// MongoDB URIs don't parse as URLs, so the credentials are cut out by hand
+// for those.
+func RedactURL(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil {
+		return parsed.Redacted()
+	}
+	scheme, rest, found := strings.Cut(uri, "://")
+	if !found {
+		return uri
+	}
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		user, _, _ := strings.Cut(rest[:at], ":")
+		return scheme + "://" + user + ":xxxxx@" + rest[at+1:]
+	}
+	return uri
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"semantic-auth/models"
+	"semantic-auth/utils"
+)
+
+// Requirements names the settings a consumer can't do without. Settings
+// that are given are checked either way.
+type Requirements struct {
+	// Embedding requires the moderation service and an OpenAI key, for the
+	// server and for commands that embed phrases
+	Embedding bool
+}
+
+// Validate checks every setting and returns one error per problem,
+// naming the setting by its environment variable
+func (c *Config) Validate(req Requirements) []error {
+	var errs []error
+	check := func(ok bool, env, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", env, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "PORT", "must be between 1 and 65535, got %d", c.Server.Port)
+	check(len(c.Server.CORSOrigins) > 0, "CORS_ALLOWED_ORIGINS", "must list at least one origin")
+	check(c.Server.ReadTimeout > 0, "SERVER_READ_TIMEOUT", "must be positive")
+	check(c.Server.WriteTimeout > 0, "SERVER_WRITE_TIMEOUT", "must be positive")
+	check(c.Server.IdleTimeout > 0, "SERVER_IDLE_TIMEOUT", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT", "must be positive")
+
+	check(slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.Log.Level)),
+		"LOG_LEVEL", "must be debug, info, warn or error, got %q", c.Log.Level)
+
+	if c.Tracing.Endpoint != "" {
+		check(isHTTPURL(c.Tracing.Endpoint), "OTEL_EXPORTER_OTLP_ENDPOINT", "must be an http(s) URL")
+	}
+	if c.Tracing.TracesEndpoint != "" {
+		check(isHTTPURL(c.Tracing.TracesEndpoint), "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "must be an http(s) URL")
+	}
+
+	check(strings.HasPrefix(c.Mongo.URI, "mongodb://") || strings.HasPrefix(c.Mongo.URI, "mongodb+srv://"),
+		"MONGO_URI", "must start with mongodb:// or mongodb+srv://")
+	_, err := models.ParseVectorEncoding(c.Mongo.VectorEncoding)
+	check(err == nil, "VECTOR_ENCODING", "must be float32 or int8, got %q", c.Mongo.VectorEncoding)
+
+	if req.Embedding {
+		check(c.Moderation.URL != "", "MODERATION_SERVICE_URL", "is required; every phrase is moderated before it is embedded")
+		check(c.OpenAI.APIKey != "", "OPENAI_KEY", "is required")
+	}
+	if c.Moderation.URL != "" {
+		check(isHTTPURL(c.Moderation.URL), "MODERATION_SERVICE_URL", "must be an http(s) URL")
+	}
+	check(c.OpenAI.Dimensions > 0 && c.OpenAI.Dimensions <= models.DefaultEmbeddingDimensions,
+		"EMBEDDING_DIMENSIONS", "must be between 1 and %d, got %d", models.DefaultEmbeddingDimensions, c.OpenAI.Dimensions)
+
+	switch c.Cache.Backend {
+	case "http":
+		if c.Cache.Enabled {
+			check(isHTTPURL(c.Cache.URL), "SEMANTIC_CACHE_URL", "must be an http(s) URL for the http backend")
+		}
+	case "redis":
+		if c.Cache.Enabled {
+			check(strings.HasPrefix(c.Cache.URL, "redis://") || strings.HasPrefix(c.Cache.URL, "rediss://"),
+				"SEMANTIC_CACHE_URL", "must be a redis:// or rediss:// URL for the redis backend")
+		}
+	default:
+		check(false, "SEMANTIC_CACHE_BACKEND", "must be http or redis, got %q", c.Cache.Backend)
+	}
+	check(c.Cache.TTL >= 0, "SEMANTIC_CACHE_TTL", "must not be negative")
+	check(c.Cache.SimilarityThreshold > 0 && c.Cache.SimilarityThreshold <= 1,
+		"SEMANTIC_CACHE_THRESHOLD", "must be in (0, 1], got %v", c.Cache.SimilarityThreshold)
+	check(c.Cache.LocalSize >= 0, "EMBEDDING_LRU_SIZE", "must not be negative")
+	check(c.Cache.LocalTTL >= 0, "EMBEDDING_LRU_TTL", "must not be negative")
+
+	check(c.Scoring.Threshold > 0 && c.Scoring.Threshold <= 1, "LOGIN_THRESHOLD", "must be in (0, 1], got %v", c.Scoring.Threshold)
+	_, err = utils.ScorerByName(c.Scoring.Scorer)
+	check(err == nil, "SIMILARITY_SCORER", "%v", err)
+	_, err = utils.AggregatorByName(c.Scoring.Aggregator)
+	check(err == nil, "SIMILARITY_AGGREGATOR", "%v", err)
+
+	check(c.Lockout.Attempts >= 0, "LOGIN_LOCKOUT_ATTEMPTS", "must not be negative")
+	check(c.Lockout.Duration > 0, "LOGIN_LOCKOUT_DURATION", "must be positive")
+
+	check(c.Session.TTL > 0, "SESSION_TTL", "must be positive")
+
+	for _, name := range c.Readiness.Critical {
+		check(slices.Contains(Dependencies, name), "READINESS_CRITICAL",
+			"unknown dependency %q, expected one of %s", name, strings.Join(Dependencies, ", "))
+	}
+
+	return errs
+}
+
+func isHTTPURL(s string) bool {
+	parsed, err := url.Parse(s)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AttemptFilter selects login attempts to purge
+type AttemptFilter struct {
+	Username string    // only this user's attempts
+	Before   time.Time // only attempts older than this
+}
+
+// PurgeAttempts deletes the login attempts matching filter and returns how
+// many were removed. An empty filter removes every attempt.
+func PurgeAttempts(ctx context.Context, filter AttemptFilter) (int64, error) {
+	query := bson.M{}
+	if filter.Username != "" {
+		query["username"] = filter.Username
+	}
+	if !filter.Before.IsZero() {
+		query["timestamp"] = bson.M{"$lt": filter.Before}
+	}
+
+	result, err := Client.Database("semantic_auth").Collection("login_attempts").DeleteMany(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
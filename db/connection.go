@@ -2,29 +2,53 @@ package db
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
+	"semantic-auth/config"
+	"semantic-auth/models"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
 var Client *mongo.Client
 
-func Connect() {
+// Connect opens the MongoDB client and selects the vector encoding
+func Connect(cfg config.Mongo) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	uri := os.Getenv("MONGO_URI")
-	if uri == "" {
-		uri = "mongodb://localhost:27017"
-	}
-	log.Println("Connecting to MongoDB at:", uri)
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	slog.Info("Connecting to MongoDB", "uri", config.RedactURL(cfg.URI))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
 	if err != nil {
-		log.Fatal("Mongo connection failed:", err)
+		slog.Error("Mongo connection failed", "error", err)
+		os.Exit(1)
 	}
 
 	Client = client
+
+	// Connecting is lazy, so check the server is actually reachable
+	if err := Ping(ctx); err != nil {
+		slog.Warn("MongoDB is not reachable yet", "error", err)
+	}
+
+	// Select how vectors are written; existing documents in any encoding stay readable.
+	// The encoding was validated when the configuration was loaded
+	models.StoredVectorEncoding, _ = models.ParseVectorEncoding(cfg.VectorEncoding)
+}
+
+// Ping checks that the primary is reachable
+func Ping(ctx context.Context) error {
+	return Client.Ping(ctx, readpref.Primary())
+}
+
+// Disconnect closes the client's connections
+func Disconnect(ctx context.Context) error {
+	if Client == nil {
+		return nil
+	}
+	return Client.Disconnect(ctx)
 }
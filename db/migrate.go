@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MigrateVectors rewrites stored vectors, the primary one and any additional
+// phrases, in models.StoredVectorEncoding and records their model and
+// dimensions. By default only legacy documents (any vector an array of
+// doubles, or no recorded dimensions) are touched; with all set, every vector
+// is re-encoded (e.g. after switching to int8).
+// Returns the number of documents updated.
+func MigrateVectors(ctx context.Context, all bool) (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"vector": bson.M{"$type": "array"}},
+		bson.M{"vectors": bson.M{"$elemMatch": bson.M{"$type": "array"}}},
+		bson.M{"dimensions": bson.M{"$exists": false}},
+	}}
+	if all {
+		filter = bson.M{"$or": bson.A{
+			bson.M{"vector": bson.M{"$exists": true}},
+			bson.M{"vectors": bson.M{"$exists": true}},
+		}}
+	}
+
+	var migrated int64
+	for _, name := range []string{"users", "embeddings"} {
+		coll := Client.Database("semantic_auth").Collection(name)
+
+		cursor, err := coll.Find(ctx, filter)
+		if err != nil {
+			return migrated, fmt.Errorf("query %s: %w", name, err)
+		}
+
+		for cursor.Next(ctx) {
+			var doc struct {
+				ID      primitive.ObjectID `bson:"_id"`
+				Vector  models.Vector      `bson:"vector"`
+				Vectors []models.Vector    `bson:"vectors"`
+				Model   string             `bson:"model"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				cursor.Close(ctx)
+				return migrated, fmt.Errorf("decode %s document: %w", name, err)
+			}
+
+			// Everything stored before models were recorded came from the default model
+			if doc.Model == "" {
+				doc.Model = models.DefaultEmbeddingModel
+			}
+
+			update := bson.M{
+				"vector":     doc.Vector,
+				"model":      doc.Model,
+				"dimensions": len(doc.Vector),
+			}
+			// Additional phrases are rewritten in the same update, so a
+			// user's vectors never end up in mixed encodings
+			if doc.Vectors != nil {
+				update["vectors"] = doc.Vectors
+			}
+
+			_, err := coll.UpdateByID(ctx, doc.ID, bson.M{"$set": update})
+			if err != nil {
+				cursor.Close(ctx)
+				return migrated, fmt.Errorf("update %s %s: %w", name, doc.ID.Hex(), err)
+			}
+			migrated++
+		}
+
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return migrated, fmt.Errorf("iterate %s: %w", name, err)
+		}
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrUserNotFound is returned when no user has the given username
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrUserExists is returned when inserting a username that is already taken
+var ErrUserExists = errors.New("user already exists")
+
+// UserFilter selects users for listing, export and re-embedding
+type UserFilter struct {
+	Prefix string // username prefix
+	Locked bool   // only users locked out right now
+}
+
+func (f UserFilter) query() bson.M {
+	filter := bson.M{}
+	if f.Prefix != "" {
+		filter["username"] = bson.M{"$regex": "^" + regexp.QuoteMeta(f.Prefix)}
+	}
+	if f.Locked {
+		filter["locked_until"] = bson.M{"$gt": time.Now()}
+	}
+	return filter
+}
+
+// EachUser calls fn for every user matching filter, in username order,
+// stopping at the first error
+func EachUser(ctx context.Context, filter UserFilter, limit int64, fn func(models.User) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "username", Value: 1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := Client.Database("semantic_auth").Collection("users").Find(ctx, filter.query(), opts)
+	if err != nil {
+		return fmt.Errorf("query users: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return fmt.Errorf("decode user: %w", err)
+		}
+		if err := fn(user); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// FindUser loads one user
+func FindUser(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	err := Client.Database("semantic_auth").Collection("users").
+		FindOne(ctx, bson.M{"username": username}).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return user, ErrUserNotFound
+	}
+	return user, err
+}
+
+// InsertUser stores a new user, or overwrites an existing one when replace is set
+func InsertUser(ctx context.Context, user models.User, replace bool) error {
+	coll := Client.Database("semantic_auth").Collection("users")
+	if replace {
+		_, err := coll.ReplaceOne(ctx, bson.M{"username": user.Username}, user, options.Replace().SetUpsert(true))
+		return err
+	}
+
+	count, err := coll.CountDocuments(ctx, bson.M{"username": user.Username})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+	_, err = coll.InsertOne(ctx, user)
+	return err
+}
+
+// DeleteUser removes a user, and their login attempts when attempts is set.
+// Returns the number of attempts removed.
+func DeleteUser(ctx context.Context, username string, attempts bool) (int64, error) {
+	result, err := Client.Database("semantic_auth").Collection("users").
+		DeleteOne(ctx, bson.M{"username": username})
+	if err != nil {
+		return 0, err
+	}
+	if result.DeletedCount == 0 {
+		return 0, ErrUserNotFound
+	}
+	if !attempts {
+		return 0, nil
+	}
+	return PurgeAttempts(ctx, AttemptFilter{Username: username})
+}
+
+// UnlockUser clears a user's lockout and failed login count
+func UnlockUser(ctx context.Context, username string) error {
+	return updateUser(ctx, username, bson.M{"$set": bson.M{"failed_attempts": 0}, "$unset": bson.M{"locked_until": ""}})
+}
+
+// SetScoring sets a user's own scorer and aggregator; empty names revert to
+// the deployment defaults
+func SetScoring(ctx context.Context, username, scorer, aggregator string) error {
+	set, unset := bson.M{}, bson.M{}
+	for field, name := range map[string]string{"scorer": scorer, "aggregator": aggregator} {
+		if name == "" {
+			unset[field] = ""
+		} else {
+			set[field] = name
+		}
+	}
+
+	update := bson.M{}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return updateUser(ctx, username, update)
+}
+
+// SetPhrases replaces a user's enrolled phrases with vectors embedded by
+// model. The first vector is the primary phrase, hashed as hash, and raw
+// is its text (empty to not store it).
+func SetPhrases(ctx context.Context, username, hash, raw, model string, vectors [][]float32) error {
+	set := bson.M{
+		"hash":       hash,
+		"vector":     models.Vector(vectors[0]),
+		"model":      model,
+		"dimensions": len(vectors[0]),
+	}
+	unset := bson.M{}
+
+	if len(vectors) > 1 {
+		extra := make([]models.Vector, len(vectors)-1)
+		for i, v := range vectors[1:] {
+			extra[i] = v
+		}
+		set["vectors"] = extra
+	} else {
+		unset["vectors"] = ""
+	}
+	if raw != "" {
+		set["raw"] = raw
+	} else {
+		unset["raw"] = ""
+	}
+
+	return updateUser(ctx, username, bson.M{"$set": set, "$unset": unset})
+}
+
+func updateUser(ctx context.Context, username string, update bson.M) error {
+	result, err := Client.Database("semantic_auth").Collection("users").
+		UpdateOne(ctx, bson.M{"username": username}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"semantic-auth/metrics"
+)
+
+// Type names an authentication event
+type Type string
+
+const (
+	// UserRegistered is published after a new user is stored; Data is nil
+	UserRegistered Type = "user.registered"
+
+	// LoginSucceeded and LoginFailed are published for every scored login
+	// attempt; Data is the models.LoginAttempt
+	LoginSucceeded Type = "login.succeeded"
+	LoginFailed    Type = "login.failed"
+
+	// AccountLocked is published when repeated failures lock an account; Data is a LockoutData
+	AccountLocked Type = "account.locked"
+
+	// ModerationRejected is published when moderation rejects a phrase; Data is the models.ModerationEvent
+	ModerationRejected Type = "moderation.rejected"
+)
+
+// Types lists every event type, for validating subscriptions
+var Types = []Type{UserRegistered, LoginSucceeded, LoginFailed, AccountLocked, ModerationRejected}
+
+// LockoutData describes an account lockout
+type LockoutData struct {
+	LockedUntil    time.Time `json:"locked_until"`
+	FailedAttempts int       `json:"failed_attempts"`
+}
+
+// Event is a message published on the bus
+type Event struct {
+	Type      Type        `json:"type"`
+	Username  string      `json:"username"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+// Bus is an in-process publish/subscribe hub. Publishing never blocks:
+// a subscriber that falls behind its buffer misses events, which are counted,
+// logged and handed to its drop handler.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription receives events on C until Close is called
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	bus    *Bus
+	name   string
+	onDrop func(Event)
+	closed bool
+}
+
+// Default is the bus the handlers publish to
+var Default = NewBus()
+
+// NewBus creates an empty bus
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[*Subscription]struct{})}
+}
+
+// Subscribe registers a subscriber with room for buffer pending events.
+// name labels its dropped events in metrics and logs. onDrop, if not nil, is
+// called with each event that didn't fit; it runs on the publisher's
+// goroutine and must not block.
+func (b *Bus) Subscribe(name string, buffer int, onDrop func(Event)) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{C: ch, ch: ch, bus: b, name: name, onDrop: onDrop}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Close unregisters the subscription and closes its channel
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subscribers, s)
+	close(s.ch)
+}
+
+// Publish delivers an event to every subscriber with room for it
+func (b *Bus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			// Slow subscriber; drop rather than block the publisher
+			metrics.EventsDropped.WithLabelValues(sub.name).Inc()
+			slog.Warn("Event dropped for slow subscriber", "subscriber", sub.name, "type", event.Type, "username", event.Username)
+			if sub.onDrop != nil {
+				sub.onDrop(event)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package events
+
+import "testing"
+
+func TestPublishHandsDroppedEventsToTheSubscriber(t *testing.T) {
+	bus := NewBus()
+
+	var dropped []Event
+	slow := bus.Subscribe("slow", 1, func(e Event) { dropped = append(dropped, e) })
+	defer slow.Close()
+	roomy := bus.Subscribe("roomy", 3, nil)
+	defer roomy.Close()
+
+	for _, username := range []string{"a", "b", "c"} {
+		bus.Publish(Event{Type: LoginFailed, Username: username})
+	}
+
+	if got := (<-slow.C).Username; got != "a" {
+		t.Errorf("slow subscriber got %q, want a", got)
+	}
+	if len(dropped) != 2 || dropped[0].Username != "b" || dropped[1].Username != "c" {
+		t.Errorf("dropped %+v, want b and c", dropped)
+	}
+	if len(roomy.C) != 3 {
+		t.Errorf("roomy subscriber has %d events, want 3", len(roomy.C))
+	}
+	if dropped[0].Timestamp.IsZero() {
+		t.Error("dropped event has no timestamp")
+	}
+
+	// Closed subscriptions get nothing, not even drops
+	slow.Close()
+	bus.Publish(Event{Type: LoginFailed, Username: "d"})
+	if len(dropped) != 2 {
+		t.Errorf("closed subscription saw a drop: %+v", dropped)
+	}
+}
@@ -70,10 +70,9 @@ const LoginAttemptsChart = ({ reportData, threshold }: LoginAttemptsChartProps)
       return { datasets: [] };
     }
 
-    // Sort by timestamp and take the most recent 100 attempts
+    // Sort by timestamp; paging in the report controls how many attempts are loaded
     const recentAttempts = [...reportData]
-      .sort((a, b) => new Date(a.timestamp).getTime() - new Date(b.timestamp).getTime())
-      .slice(-100);
+      .sort((a, b) => new Date(a.timestamp).getTime() - new Date(b.timestamp).getTime());
 
     // Define chart point type
     interface ChartPoint {
@@ -3,6 +3,7 @@ import authService from '../services/authService';
 import LoginAttemptsChart from './LoginAttemptsChart';
 
 interface LoginAttempt {
+  id?: string;
   username?: string;
   input: string;
   similarity: number;
@@ -12,7 +13,11 @@ interface LoginAttempt {
 
 const Report = () => {
   const [threshold, setThreshold] = useState(0.88);
+  // Kept for the browser session only, never in localStorage
+  const [adminToken, setAdminToken] = useState(() => sessionStorage.getItem('adminToken') || '');
   const [reportData, setReportData] = useState<LoginAttempt[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | undefined>(undefined);
+  const [total, setTotal] = useState(0);
   const [isLoading, setIsLoading] = useState(false);
   const [error, setError] = useState('');
   const [chartView, setChartView] = useState(true); // Default to chart view
@@ -20,23 +25,47 @@ const Report = () => {
 
   // Define fetchReport with useCallback to prevent it from changing on every render
   const fetchReport = useCallback(async () => {
+    if (!adminToken) {
+      setReportData([]);
+      setNextCursor(undefined);
+      return;
+    }
     setIsLoading(true);
     setError('');
     try {
-      const response = await authService.getReport(threshold.toString());
+      const response = await authService.getReport(adminToken, threshold.toString());
       if (response.success) {
-        setReportData(response.data || []);
+        setReportData(response.data?.attempts || []);
+        setNextCursor(response.data?.next_cursor);
+        setTotal(response.data?.total || 0);
       } else {
         setError(response.message || 'Failed to fetch report data');
         setReportData([]);
+        setNextCursor(undefined);
       }
     } catch (err) {
       setError('Error fetching report: ' + (err instanceof Error ? err.message : String(err)));
       setReportData([]);
+      setNextCursor(undefined);
     } finally {
       setIsLoading(false);
     }
-  }, [threshold]);
+  }, [threshold, adminToken]);
+
+  // Append the next page of older attempts
+  const fetchMore = async () => {
+    if (!nextCursor) {
+      return;
+    }
+    setError('');
+    const response = await authService.getReport(adminToken, threshold.toString(), nextCursor);
+    if (response.success) {
+      setReportData((current) => [...current, ...(response.data?.attempts || [])]);
+      setNextCursor(response.data?.next_cursor);
+    } else {
+      setError(response.message || 'Failed to fetch more report data');
+    }
+  };
 
   useEffect(() => {
     fetchReport();
@@ -57,6 +86,20 @@ const Report = () => {
         <h2>Login Attempts Report</h2>
         
         <div className="report-controls">
+          <div className="threshold-control">
+            <label htmlFor="admin-token">Admin Token:</label>
+            <input
+              type="password"
+              id="admin-token"
+              value={adminToken}
+              onChange={(e) => {
+                setAdminToken(e.target.value);
+                sessionStorage.setItem('adminToken', e.target.value);
+              }}
+              autoComplete="off"
+            />
+          </div>
+
           <div className="threshold-control">
             <label htmlFor="threshold">Similarity Threshold:</label>
             <div className="numeric-input-container">
@@ -134,7 +177,7 @@ const Report = () => {
               <tbody>
                 {reportData.map((attempt, index) => (
                   <tr 
-                    key={index} 
+                    key={attempt.id || index} 
                     className={attempt.passed ? 'success-row' : 'failure-row'}
                   >
                     <td>{attempt.input}</td>
@@ -146,9 +189,22 @@ const Report = () => {
               </tbody>
             </table>
           </div>
+        ) : !adminToken ? (
+          <div className="no-data">Enter the admin token to view login attempts</div>
         ) : (
           <div className="no-data">No login attempts found</div>
         )}
+
+        {reportData.length > 0 && (
+          <div className="report-paging">
+            <span>Showing {reportData.length} of {total} attempts</span>
+            {nextCursor && (
+              <button className="get-report-btn" onClick={fetchMore}>
+                Load More
+              </button>
+            )}
+          </div>
+        )}
       </div>
     </div>
   );
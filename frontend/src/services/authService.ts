@@ -1,6 +1,8 @@
 // API service for authentication
 // Use environment variables for API URL with fallback to localhost for development
 const API_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080';
+// Versioned API routes, see /v1/openapi.json
+const API_BASE = `${API_URL}/v1`;
 
 interface RegisterRequest {
   username: string;
@@ -26,22 +28,34 @@ interface LoginResponseData {
   username: string;
   similarity: number;
   threshold: number;
+  scorer: string;
+  token: string;
+  expires_at: string;
 }
 
 // Report data structure
 interface ReportItem {
+  id: string;
+  username: string;
   input: string;
   similarity: number;
   timestamp: string;
   passed: boolean;
 }
 
+// One page of report data
+interface ReportPage {
+  attempts: ReportItem[];
+  next_cursor?: string;
+  total: number;
+}
+
 export const authService = {
   // Register a new user
   async register(username: string, password: string): Promise<ApiResponse<{username: string}>> {
     try {
       const registerData: RegisterRequest = { username, password };
-      const response = await fetch(`${API_URL}/register`, {
+      const response = await fetch(`${API_BASE}/register`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -86,7 +100,7 @@ export const authService = {
         loginData.threshold = threshold;
       }
 
-      const response = await fetch(`${API_URL}/login`, {
+      const response = await fetch(`${API_BASE}/login`, {
         method: 'POST',
         headers: {
           'Content-Type': 'application/json',
@@ -123,20 +137,24 @@ export const authService = {
     }
   },
 
-  // Get report data
-  async getReport(threshold?: string): Promise<ApiResponse<ReportItem[]>> {
+  // Get report data; the report is admin-only and needs the server's ADMIN_TOKEN
+  async getReport(adminToken: string, threshold?: string, cursor?: string): Promise<ApiResponse<ReportPage>> {
     try {
       // Build query parameters
       const params = new URLSearchParams();
       if (threshold !== undefined) {
         params.append('threshold', threshold);
       }
+      if (cursor) {
+        params.append('cursor', cursor);
+      }
       
       const queryString = params.toString() ? `?${params.toString()}` : '';
-      const response = await fetch(`${API_URL}/report${queryString}`, {
+      const response = await fetch(`${API_BASE}/report${queryString}`, {
         method: 'GET',
         headers: {
           'Accept': 'application/json',
+          'Authorization': `Bearer ${adminToken}`,
         },
       });
 
@@ -165,7 +183,7 @@ export const authService = {
         status: 'error',
         success: false,
         message: error instanceof Error ? error.message : 'Network error while fetching report data',
-        data: [],
+        data: { attempts: [], total: 0 },
       };
     }
   }
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// adminToken is the bearer token for the admin API
+var adminToken string
+
+// AdminAuth protects admin routes with the configured bearer token.
+// When no token is configured the admin API is disabled entirely.
+func AdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			RespondWithError(w, http.StatusForbidden, "Admin API is disabled")
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(api.BearerToken(r)), []byte(adminToken)) != 1 {
+			RespondWithError(w, http.StatusUnauthorized, "Invalid admin token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+type ModerationCategoryStat struct {
+	Category string  `json:"category"`
+	Count    int64   `json:"count"`
+	Share    float64 `json:"share"` // fraction of all rejections in the window, not a rejection rate
+}
+
+type ModerationStatsResponse struct {
+	From       time.Time                `json:"from"`
+	To         time.Time                `json:"to"`
+	Total      int64                    `json:"total"`
+	Categories []ModerationCategoryStat `json:"categories"`
+}
+
+// ModerationStatsHandler reports moderation rejections grouped by category
+func ModerationStatsHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 30*24*time.Hour)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	coll := db.Client.Database("semantic_auth").Collection("moderation_events")
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"timestamp": bson.M{"$gte": from, "$lte": to}}},
+		bson.M{"$group": bson.M{"_id": "$category", "count": bson.M{"$sum": 1}}},
+		bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+
+	cursor, err := coll.Aggregate(r.Context(), pipeline)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to query moderation events")
+		return
+	}
+	defer cursor.Close(r.Context())
+
+	var groups []struct {
+		Category string `bson:"_id"`
+		Count    int64  `bson:"count"`
+	}
+	if err := cursor.All(r.Context(), &groups); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read moderation events")
+		return
+	}
+
+	resp := ModerationStatsResponse{
+		From:       from,
+		To:         to,
+		Categories: []ModerationCategoryStat{},
+	}
+	for _, g := range groups {
+		resp.Total += g.Count
+	}
+	for _, g := range groups {
+		resp.Categories = append(resp.Categories, ModerationCategoryStat{
+			Category: g.Category,
+			Count:    g.Count,
+			Share:    float64(g.Count) / float64(resp.Total),
+		})
+	}
+
+	RespondWithSuccess(w, "Moderation statistics retrieved successfully", resp)
+}
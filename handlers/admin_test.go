@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"semantic-auth/api"
+)
+
+// TestAttemptDataRequiresAdmin checks that nothing listing attempted phrases
+// or usernames is served without the admin token
+func TestAttemptDataRequiresAdmin(t *testing.T) {
+	saved := adminToken
+	adminToken = "test-admin-token"
+	defer func() { adminToken = saved }()
+
+	router := testRouter()
+	paths := []string{
+		"/report",
+		"/report/simulate?thresholds=0.8",
+		"/report/export",
+		"/report/stream",
+		"/stats",
+	}
+	for _, path := range paths {
+		for _, token := range []string{"", "wrong-token"} {
+			req := httptest.NewRequest(http.MethodGet, api.Prefix+path, nil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("GET %s with token %q: status %d, want 401", path, token, rec.Code)
+			}
+		}
+	}
+}
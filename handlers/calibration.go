@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"semantic-auth/analysis"
+	"semantic-auth/db"
+	"semantic-auth/models"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LabelRequest struct {
+	Label string `json:"label"` // "genuine", "impostor", or "" to clear
+}
+
+// LabelResponse is the label now set on an attempt
+type LabelResponse struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// LabelAttemptHandler sets the operator label on a login attempt
+func LabelAttemptHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid attempt ID")
+		return
+	}
+
+	var req LabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"label": req.Label}}
+	switch req.Label {
+	case models.LabelGenuine, models.LabelImpostor:
+	case "":
+		update = bson.M{"$unset": bson.M{"label": ""}}
+	default:
+		RespondWithError(w, http.StatusBadRequest, "Label must be 'genuine', 'impostor' or empty")
+		return
+	}
+
+	result, err := db.Client.Database("semantic_auth").Collection("login_attempts").
+		UpdateByID(r.Context(), id, update)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to label login attempt")
+		return
+	}
+	if result.MatchedCount == 0 {
+		RespondWithError(w, http.StatusNotFound, "Login attempt not found")
+		return
+	}
+
+	RespondWithSuccess(w, "Login attempt labeled successfully", LabelResponse{ID: id.Hex(), Label: req.Label})
+}
+
+// CalibrationHandler computes false-accept/false-reject rates, the ROC curve
+// and the equal-error-rate threshold from labeled login attempts
+func CalibrationHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, err := parseTimeRange(r, 90*24*time.Hour)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	opts := analysis.CalibrationOptions{
+		Username:         strings.ToLower(strings.TrimSpace(query.Get("username"))),
+		From:             from,
+		To:               to,
+		InferLabels:      query.Get("infer") == "true",
+		InferWindow:      10 * time.Minute,
+		DefaultThreshold: DefaultThreshold,
+		PerUser:          query.Get("per_user") == "true",
+		Step:             0.01,
+	}
+
+	if windowStr := query.Get("window"); windowStr != "" {
+		window, err := time.ParseDuration(windowStr)
+		if err != nil || window <= 0 {
+			RespondWithError(w, http.StatusBadRequest, "Invalid window duration")
+			return
+		}
+		opts.InferWindow = window
+	}
+
+	if stepStr := query.Get("step"); stepStr != "" {
+		step, err := strconv.ParseFloat(stepStr, 64)
+		if err != nil || step <= 0 || step > 0.5 {
+			RespondWithError(w, http.StatusBadRequest, "Step must be between 0 and 0.5")
+			return
+		}
+		opts.Step = step
+	}
+
+	report, err := analysis.Calibrate(r.Context(), opts)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to calibrate threshold")
+		return
+	}
+
+	RespondWithSuccess(w, "Calibration computed successfully", report)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"semantic-auth/db"
+	"semantic-auth/models"
+)
+
+// exportFlushEvery controls how often buffered export rows are flushed to the client
+const exportFlushEvery = 100
+
+// ExportHandler streams every login attempt matching the /report filters as
+// CSV or newline-delimited JSON, chosen by the format parameter or the Accept
+// header. Rows are written straight from the Mongo cursor; limit and cursor
+// are ignored.
+func ExportHandler(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "application/x-ndjson"), strings.Contains(accept, "application/jsonl"):
+			format = "ndjson"
+		default:
+			format = "csv"
+		}
+	}
+	if format != "csv" && format != "ndjson" {
+		RespondWithError(w, http.StatusBadRequest, "format must be 'csv' or 'ndjson'")
+		return
+	}
+
+	q, err := parseReportQuery(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	coll := db.Client.Database("semantic_auth").Collection("login_attempts")
+	cursor, err := coll.Find(r.Context(), q.filter, q.findOptions())
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to query login attempts")
+		return
+	}
+	defer cursor.Close(r.Context())
+
+	filename := "login_attempts." + format
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.WriteHeader(http.StatusOK)
+
+	// Large exports outlast the server write timeout
+	disableWriteTimeout(w)
+
+	flusher, _ := w.(http.Flusher)
+	csvWriter := csv.NewWriter(w)
+	jsonEncoder := json.NewEncoder(w)
+
+	rows := 0
+	// fail ends a stream that has already started. The status line is gone, so
+	// the connection is dropped before the final chunk and the client sees a
+	// truncated transfer instead of a complete-looking file.
+	fail := func(msg string, err error) {
+		slog.ErrorContext(r.Context(), msg, "error", err, "format", format, "rows", rows)
+		panic(http.ErrAbortHandler)
+	}
+
+	if format == "csv" {
+		if err := csvWriter.Write([]string{"id", "username", "input", "similarity", "passed", "timestamp"}); err != nil {
+			fail("Failed to write export header", err)
+		}
+	}
+
+	for cursor.Next(r.Context()) {
+		var attempt models.LoginAttempt
+		if err := cursor.Decode(&attempt); err != nil {
+			fail("Failed to decode login attempt", err)
+		}
+		row := toReportResponse(attempt, q.threshold)
+
+		if format == "csv" {
+			err = csvWriter.Write([]string{
+				csvCell(row.ID),
+				csvCell(row.Username),
+				csvCell(row.Input),
+				strconv.FormatFloat(row.Similarity, 'f', -1, 64),
+				strconv.FormatBool(row.Passed),
+				row.Timestamp.UTC().Format(time.RFC3339Nano),
+			})
+		} else {
+			err = jsonEncoder.Encode(row)
+		}
+		if err != nil {
+			fail("Failed to write export row", err)
+		}
+
+		rows++
+		if rows%exportFlushEvery == 0 {
+			csvWriter.Flush()
+			if err := csvWriter.Error(); err != nil {
+				fail("Failed to write export rows", err)
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		fail("Export cursor failed", err)
+	}
+
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		fail("Failed to write export rows", err)
+	}
+}
+
+// csvCell neutralises text a spreadsheet would run as a formula by prefixing
+// it with a quote, as recommended by OWASP for CSV exports
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
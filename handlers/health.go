@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"semantic-auth/cache"
+	"semantic-auth/db"
+	"semantic-auth/moderation"
+	"semantic-auth/openai"
+)
+
+// readinessTimeout bounds each dependency check
+const readinessTimeout = 3 * time.Second
+
+// errDisabled marks a dependency that is switched off rather than failing
+var errDisabled = errors.New("disabled")
+
+// dependency is one readiness check
+type dependency struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// dependencies are checked by /health/ready, in report order
+var dependencies = []dependency{
+	{"mongo", db.Ping},
+	{"moderation", func(ctx context.Context) error {
+		_, err := moderation.HealthCheck(ctx)
+		return err
+	}},
+	{"cache", func(ctx context.Context) error {
+		if cache.DefaultClient == nil || !cache.DefaultClient.IsEnabled() {
+			return errDisabled
+		}
+		if !cache.DefaultClient.HealthCheck(ctx) {
+			return errors.New("cache health check failed")
+		}
+		return nil
+	}},
+	{"openai", func(ctx context.Context) error {
+		return openai.CheckConfig()
+	}},
+}
+
+// criticalDependencies fail readiness when unhealthy; the others only
+// degrade it. The embedding cache falls back to Mongo and OpenAI, so it is
+// not critical by default.
+var criticalDependencies = map[string]bool{
+	"mongo":      true,
+	"moderation": true,
+	"openai":     true,
+}
+
+// DependencyStatus is the readiness of one dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"` // "ok", "failing" or "disabled"
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// ReadinessResponse is the /health/ready body
+type ReadinessResponse struct {
+	Status       string                      `json:"status"` // "ready", "degraded" or "unavailable"
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// LiveHandler reports that the process is up, without touching dependencies
+func LiveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ReadyHandler checks every dependency concurrently. It answers 503 when a
+// critical dependency is failing and 200 otherwise, reporting non-critical
+// failures as degraded.
+func ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	response := ReadinessResponse{
+		Status:       "ready",
+		Dependencies: make(map[string]DependencyStatus, len(dependencies)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, dep := range dependencies {
+		wg.Add(1)
+		go func(dep dependency) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := dep.check(ctx)
+			status := DependencyStatus{
+				Status:    "ok",
+				Critical:  criticalDependencies[dep.name],
+				LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			}
+			if errors.Is(err, errDisabled) {
+				status.Status = "disabled"
+			} else if err != nil {
+				status.Status = "failing"
+				status.Error = err.Error()
+			}
+
+			mu.Lock()
+			response.Dependencies[dep.name] = status
+			mu.Unlock()
+		}(dep)
+	}
+	wg.Wait()
+
+	for _, status := range response.Dependencies {
+		if status.Status != "failing" {
+			continue
+		}
+		if status.Critical {
+			response.Status = "unavailable"
+		} else if response.Status == "ready" {
+			response.Status = "degraded"
+		}
+	}
+
+	code := http.StatusOK
+	if response.Status == "unavailable" {
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// lockoutAttempts is how many consecutive failed logins lock an account; 0 disables lockouts
+	lockoutAttempts = 0
+
+	// lockoutDuration is how long a locked account stays locked
+	lockoutDuration = 15 * time.Minute
+)
+
+// isLocked reports whether the user is currently locked out
+func isLocked(user models.User) bool {
+	return lockoutAttempts > 0 && user.LockedUntil.After(time.Now())
+}
+
+// recordFailure counts a failed login and locks the account once the count
+// reaches lockoutAttempts
+func recordFailure(ctx context.Context, username string) {
+	if lockoutAttempts <= 0 {
+		return
+	}
+
+	coll := db.Client.Database("semantic_auth").Collection("users")
+
+	var user models.User
+	err := coll.FindOneAndUpdate(ctx,
+		bson.M{"username": username},
+		bson.M{"$inc": bson.M{"failed_attempts": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record failed login", "username", username, "error", err)
+		return
+	}
+
+	if user.FailedAttempts < lockoutAttempts {
+		return
+	}
+
+	lockedUntil := time.Now().Add(lockoutDuration)
+	_, err = coll.UpdateOne(ctx,
+		bson.M{"username": username},
+		bson.M{"$set": bson.M{"locked_until": lockedUntil, "failed_attempts": 0}},
+	)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to lock account", "username", username, "error", err)
+		return
+	}
+
+	slog.WarnContext(ctx, "Account locked", "username", username, "until", lockedUntil, "failed_attempts", user.FailedAttempts)
+	events.Default.Publish(events.Event{
+		Type:     events.AccountLocked,
+		Username: username,
+		Data: events.LockoutData{
+			LockedUntil:    lockedUntil,
+			FailedAttempts: user.FailedAttempts,
+		},
+	})
+}
+
+// resetFailures clears the failed login count after a successful login
+func resetFailures(ctx context.Context, user models.User) {
+	if user.FailedAttempts == 0 {
+		return
+	}
+
+	_, err := db.Client.Database("semantic_auth").Collection("users").
+		UpdateOne(ctx, bson.M{"username": user.Username}, bson.M{"$set": bson.M{"failed_attempts": 0}})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to reset failed logins", "username", user.Username, "error", err)
+	}
+}
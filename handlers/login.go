@@ -2,27 +2,29 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
 
+	"semantic-auth/api"
 	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/logging"
+	"semantic-auth/metrics"
 	"semantic-auth/models"
+	"semantic-auth/moderation"
 	"semantic-auth/openai"
+	"semantic-auth/sessions"
 	"semantic-auth/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-type LoginRequest struct {
-	Username  string  `json:"username"`
-	Password  string  `json:"password"`
-	Threshold float64 `json:"threshold"` // optional
-}
-
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	var req LoginRequest
+	var req api.LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
@@ -36,10 +38,18 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set default threshold if not provided
-	threshold := req.Threshold
-	if threshold == 0 {
-		threshold = 0.88
+	// Keep the guess out of anything logged for this request
+	logging.SetUsername(r.Context(), req.Username)
+	logging.AddPhrases(r.Context(), req.Password)
+
+	// A caller may demand a closer match, but never a looser one
+	threshold := DefaultThreshold
+	if req.Threshold != 0 {
+		if req.Threshold < DefaultThreshold || req.Threshold > 1 {
+			RespondWithError(w, http.StatusBadRequest, "Threshold must be between the configured threshold and 1")
+			return
+		}
+		threshold = req.Threshold
 	}
 
 	// Get user
@@ -47,51 +57,103 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	var user models.User
 	err = userColl.FindOne(r.Context(), bson.M{"username": req.Username}).Decode(&user)
 	if err != nil {
-		RespondWithError(w, http.StatusUnauthorized, "User not found")
+		metrics.LoginAttempts.WithLabelValues("unknown_user").Inc()
+		RespondWithErrorCode(w, http.StatusUnauthorized, api.CodeUserNotFound, "User not found")
+		return
+	}
+
+	if isLocked(user) {
+		metrics.LoginAttempts.WithLabelValues("locked").Inc()
+		RespondWithError(w, http.StatusLocked, "Account is temporarily locked after too many failed attempts")
 		return
 	}
 
 	// Embed the guessed password
-	guessVec, err := openai.Embed(req.Password)
+	guessVec, err := openai.Embed(r.Context(), req.Password)
 	if err != nil {
 		// Check if this is a moderation error
-		if strings.Contains(err.Error(), "moderation error") {
-			log.Println("Moderation error:", err)
-			RespondWithError(w, http.StatusBadRequest, err.Error())
+		var rejected *moderation.RejectedError
+		if errors.As(err, &rejected) {
+			slog.InfoContext(r.Context(), "Phrase rejected by moderation", "error", err)
+			metrics.LoginAttempts.WithLabelValues("rejected").Inc()
+			moderation.RecordRejection(r.Context(), req.Username, req.Password, rejected.Response)
+			RespondWithErrorCode(w, http.StatusBadRequest, api.CodeContentRejected, err.Error())
 			return
 		}
-		
+
 		// Other embedding errors
+		metrics.LoginAttempts.WithLabelValues("error").Inc()
 		RespondWithError(w, http.StatusInternalServerError, "Failed to embed password")
-		log.Println("OpenAI error:", err)
+		slog.ErrorContext(r.Context(), "Embedding failed", "error", err)
 		return
 	}
 
-	// Compare with stored
-	similarity, err := utils.CosineSimilarity(user.Vector, guessVec)
+	// Compare with stored, using the user's scorer if they have one.
+	// Users enrolled at another dimension are compared at the shorter of the two
+	scorer, aggregator := scoringFor(user)
+	similarity, err := utils.ScoreMulti(scorer, aggregator, user.AllVectors(), guessVec)
 	if err != nil {
+		metrics.LoginAttempts.WithLabelValues("error").Inc()
 		RespondWithError(w, http.StatusInternalServerError, "Similarity calculation failed")
 		return
 	}
+	metrics.LoginSimilarity.Observe(similarity)
 
 	// Log attempt
 	attempt := models.LoginAttempt{
 		Username:   req.Username,
 		Input:      req.Password,
 		Similarity: similarity,
+		Threshold:  threshold,
+		Passed:     similarity >= threshold,
+		Scorer:     scorer.Name(),
+		Aggregator: aggregator.Name(),
 		Timestamp:  time.Now(),
 	}
-	_, _ = db.Client.Database("semantic_auth").Collection("login_attempts").
+	result, err := db.Client.Database("semantic_auth").Collection("login_attempts").
 		InsertOne(r.Context(), attempt)
+	if err == nil {
+		attempt.ID, _ = result.InsertedID.(primitive.ObjectID)
+	} else {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+	}
+
+	// Let live subscribers and webhooks see the attempt
+	eventType := events.LoginFailed
+	if attempt.Passed {
+		eventType = events.LoginSucceeded
+	}
+	events.Default.Publish(events.Event{
+		Type:      eventType,
+		Username:  attempt.Username,
+		Timestamp: attempt.Timestamp,
+		Data:      attempt,
+	})
 
 	// Decide
-	if similarity >= threshold {
-		RespondWithSuccess(w, "Login successful", map[string]interface{}{
-			"username": req.Username,
-			"similarity": similarity,
-			"threshold": threshold,
+	if attempt.Passed {
+		metrics.LoginAttempts.WithLabelValues("success").Inc()
+		resetFailures(r.Context(), user)
+
+		token, session, err := sessions.Issue(r.Context(), req.Username, similarity)
+		if err != nil {
+			metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+			slog.ErrorContext(r.Context(), "Failed to create session", "error", err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to create session")
+			return
+		}
+
+		RespondWithSuccess(w, "Login successful", api.LoginResponse{
+			Username:   req.Username,
+			Similarity: similarity,
+			Threshold:  threshold,
+			Scorer:     scorer.Name(),
+			Token:      token,
+			ExpiresAt:  session.ExpiresAt,
 		})
 	} else {
-		RespondWithError(w, http.StatusUnauthorized, "Incorrect password (not semantically similar enough)")
+		metrics.LoginAttempts.WithLabelValues("failure").Inc()
+		recordFailure(r.Context(), user.Username)
+		RespondWithErrorCode(w, http.StatusUnauthorized, api.CodeInvalidCredentials, "Incorrect password (not semantically similar enough)")
 	}
 }
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"semantic-auth/api"
+	"semantic-auth/openapi"
+)
+
+// pathParamPattern matches the {name} parameters of a route path
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// OpenAPIDocument describes Routes as an OpenAPI 3 document, with request and
+// response schemas derived from the handlers' types
+func OpenAPIDocument() *openapi.Document {
+	schemas := openapi.NewSchemas()
+	errorSchema := schemas.For(api.StandardResponse{})
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info: openapi.Info{
+			Title:       "Semantic Authenticator",
+			Version:     strings.TrimPrefix(api.Prefix, "/"),
+			Description: "Authenticates users by the meaning of a passphrase rather than its exact text.",
+		},
+		Servers: []openapi.Server{{URL: api.Prefix}},
+		Paths:   map[string]openapi.PathItem{},
+	}
+
+	for _, route := range Routes {
+		op := &openapi.Operation{
+			OperationID: route.ID,
+			Summary:     route.Summary,
+			Tags:        []string{route.Tag},
+			Responses:   map[string]openapi.Response{},
+		}
+
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, route.Query...)
+
+		if route.Request != nil {
+			op.RequestBody = &openapi.RequestBody{
+				Required: true,
+				Content:  map[string]openapi.MediaType{"application/json": {Schema: schemas.For(route.Request)}},
+			}
+		}
+
+		success := openapi.Response{Description: "Success", Content: map[string]openapi.MediaType{}}
+		if len(route.MediaType) == 0 {
+			success.Content["application/json"] = openapi.MediaType{Schema: successSchema(schemas, route.Response)}
+		}
+		for _, mediaType := range route.MediaType {
+			// Each record, line or event carries one Response value
+			item := schemas.For(route.Response)
+			if mediaType != "application/x-ndjson" {
+				item = &openapi.Schema{Type: "string"}
+			}
+			success.Content[mediaType] = openapi.MediaType{Schema: item}
+		}
+		op.Responses["200"] = success
+
+		errorStatuses := route.Errors
+		if route.Admin {
+			errorStatuses = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errorStatuses...)
+			op.Security = []map[string][]string{{"adminToken": {}}}
+		}
+		if route.Session {
+			op.Security = []map[string][]string{{"sessionToken": {}}}
+		}
+		for _, status := range errorStatuses {
+			op.Responses[strconv.Itoa(status)] = openapi.Response{
+				Description: http.StatusText(status),
+				Content:     map[string]openapi.MediaType{"application/json": {Schema: errorSchema}},
+			}
+		}
+
+		path := doc.Paths[route.Path]
+		if path == nil {
+			path = openapi.PathItem{}
+			doc.Paths[route.Path] = path
+		}
+		path[strings.ToLower(route.Method)] = op
+	}
+
+	doc.Components = openapi.Components{
+		Schemas: schemas.Components(),
+		SecuritySchemes: map[string]openapi.SecurityScheme{
+			"adminToken":   {Type: "http", Scheme: "bearer"},
+			"sessionToken": {Type: "http", Scheme: "bearer"},
+		},
+	}
+	return doc
+}
+
+// successSchema is the StandardResponse envelope with data typed as the route's response
+func successSchema(schemas *openapi.Schemas, data interface{}) *openapi.Schema {
+	envelope := schemas.For(struct {
+		api.StandardResponse
+	}{})
+	if data != nil {
+		envelope.Properties["data"] = schemas.For(data)
+	} else {
+		delete(envelope.Properties, "data")
+	}
+	return envelope
+}
+
+// OpenAPIHandler serves OpenAPIDocument as JSON
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, _ = json.MarshalIndent(OpenAPIDocument(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// queryParam describes an optional query parameter. typ is a JSON schema
+// type, or "date-time" for an RFC3339 string.
+func queryParam(name, typ, description string) openapi.Parameter {
+	schema := &openapi.Schema{Type: typ}
+	if typ == "date-time" {
+		schema = &openapi.Schema{Type: "string", Format: "date-time"}
+	}
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// enumParam describes an optional query parameter taking one of values
+func enumParam(name, description string, values ...string) openapi.Parameter {
+	param := queryParam(name, "string", description)
+	param.Schema.Enum = values
+	return param
+}
+
+func requiredParam(param openapi.Parameter) openapi.Parameter {
+	param.Required = true
+	return param
+}
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/openapi"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// testRouter mounts the API the way main does
+func testRouter() chi.Router {
+	r := chi.NewRouter()
+	r.Route(api.Prefix, Mount)
+	return r
+}
+
+// operation returns the documented operation for a route
+func operation(t *testing.T, doc *openapi.Document, route Route) *openapi.Operation {
+	t.Helper()
+	op := doc.Paths[route.Path][strings.ToLower(route.Method)]
+	if op == nil {
+		t.Fatalf("%s %s is not in the OpenAPI document", route.Method, route.Path)
+	}
+	return op
+}
+
+func isAdminAuth(mw func(http.Handler) http.Handler) bool {
+	return reflect.ValueOf(mw).Pointer() == reflect.ValueOf(AdminAuth).Pointer()
+}
+
+func TestMountedRoutesMatchSpec(t *testing.T) {
+	doc := OpenAPIDocument()
+
+	documented := map[string]*openapi.Operation{}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			documented[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+
+	mounted := map[string]bool{}
+	err := chi.Walk(testRouter(), func(method, route string, _ http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		path := strings.TrimPrefix(route, api.Prefix)
+		if path == "/openapi.json" {
+			return nil
+		}
+		key := method + " " + path
+		mounted[key] = true
+
+		op, ok := documented[key]
+		if !ok {
+			t.Errorf("%s is served but not documented", key)
+			return nil
+		}
+
+		admin := false
+		for _, mw := range middlewares {
+			admin = admin || isAdminAuth(mw)
+		}
+		documentedAdmin := len(op.Security) > 0 && op.Security[0]["adminToken"] != nil
+		if admin != documentedAdmin {
+			t.Errorf("%s: behind AdminAuth = %v, documented as admin = %v", key, admin, documentedAdmin)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key := range documented {
+		if !mounted[key] {
+			t.Errorf("%s is documented but not served", key)
+		}
+	}
+}
+
+func TestPathParametersMatchSpec(t *testing.T) {
+	doc := OpenAPIDocument()
+	for _, route := range Routes {
+		op := operation(t, doc, route)
+
+		var want, got []string
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			want = append(want, match[1])
+		}
+		for _, param := range op.Parameters {
+			if param.In == "path" {
+				got = append(got, param.Name)
+			}
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("%s %s: path parameters %v, documented %v", route.Method, route.Path, want, got)
+		}
+	}
+}
+
+// TestTypesRoundTripThroughSchemas encodes a fully populated value of each
+// route's request and response type, checks the JSON against the published
+// schema in both directions, and decodes it back unchanged
+func TestTypesRoundTripThroughSchemas(t *testing.T) {
+	doc := OpenAPIDocument()
+	for _, route := range Routes {
+		op := operation(t, doc, route)
+		name := route.Method + " " + route.Path
+
+		if route.Request != nil {
+			schema := op.RequestBody.Content["application/json"].Schema
+			roundTrip(t, doc, name+" request", route.Request, schema)
+		}
+
+		if route.Response == nil {
+			continue
+		}
+		success := op.Responses["200"]
+		if len(route.MediaType) == 0 {
+			sample := sampleOf(reflect.TypeOf(route.Response))
+			envelope := api.StandardResponse{Status: "success", Success: true, Message: "ok", Data: sample.Interface()}
+			checkJSON(t, doc, name+" response", envelope, success.Content["application/json"].Schema)
+			roundTrip(t, doc, name+" response data", route.Response, schemaProperty(t, doc, success.Content["application/json"].Schema, "data"))
+		}
+		if mediaType, ok := success.Content["application/x-ndjson"]; ok {
+			roundTrip(t, doc, name+" ndjson line", route.Response, mediaType.Schema)
+		}
+	}
+}
+
+// TestErrorResponsesMatchSpec sends each route a request its handler rejects
+// before touching storage and checks the status is documented and the body
+// is the documented error envelope
+func TestErrorResponsesMatchSpec(t *testing.T) {
+	saved := adminToken
+	adminToken = "test-admin-token"
+	defer func() { adminToken = saved }()
+
+	doc := OpenAPIDocument()
+	router := testRouter()
+
+	for _, route := range Routes {
+		op := operation(t, doc, route)
+		path := api.Prefix + pathParamPattern.ReplaceAllString(route.Path, "000000000000000000000001")
+
+		var cases []*http.Request
+		if route.Admin {
+			// No token
+			cases = append(cases, httptest.NewRequest(route.Method, path, nil))
+		}
+		if route.Request != nil {
+			req := httptest.NewRequest(route.Method, path, strings.NewReader("{"))
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			cases = append(cases, req)
+		}
+		if route.Session {
+			// No session token
+			cases = append(cases, httptest.NewRequest(route.Method, path, nil))
+		}
+
+		for _, req := range cases {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			name := fmt.Sprintf("%s %s (%d)", route.Method, route.Path, rec.Code)
+			response, ok := op.Responses[fmt.Sprint(rec.Code)]
+			if !ok || rec.Code < 400 {
+				t.Errorf("%s: status is not a documented error", name)
+				continue
+			}
+
+			var body interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Errorf("%s: body is not JSON: %v", name, err)
+				continue
+			}
+			validate(t, doc, name, response.Content["application/json"].Schema, body)
+		}
+	}
+}
+
+// roundTrip checks a populated value of v's type against schema and that it
+// decodes back to the same value
+func roundTrip(t *testing.T, doc *openapi.Document, name string, v interface{}, schema *openapi.Schema) {
+	t.Helper()
+	typ := reflect.TypeOf(v)
+	sample := sampleOf(typ)
+
+	encoded := checkJSON(t, doc, name, sample.Interface(), schema)
+
+	decoded := reflect.New(typ)
+	if err := json.Unmarshal(encoded, decoded.Interface()); err != nil {
+		t.Errorf("%s: decode: %v", name, err)
+		return
+	}
+	if !reflect.DeepEqual(sample.Interface(), decoded.Elem().Interface()) {
+		t.Errorf("%s: value changed in a JSON round trip:\n got %#v\nwant %#v", name, decoded.Elem().Interface(), sample.Interface())
+	}
+}
+
+// checkJSON encodes v and validates it against schema
+func checkJSON(t *testing.T, doc *openapi.Document, name string, v interface{}, schema *openapi.Schema) []byte {
+	t.Helper()
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("%s: encode: %v", name, err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	validate(t, doc, name, schema, generic)
+	return encoded
+}
+
+func resolve(t *testing.T, doc *openapi.Document, schema *openapi.Schema) *openapi.Schema {
+	t.Helper()
+	for schema != nil && schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Fatalf("dangling reference %s", schema.Ref)
+		}
+		schema = resolved
+	}
+	return schema
+}
+
+func schemaProperty(t *testing.T, doc *openapi.Document, schema *openapi.Schema, name string) *openapi.Schema {
+	t.Helper()
+	prop := resolve(t, doc, schema).Properties[name]
+	if prop == nil {
+		t.Fatalf("schema has no %q property", name)
+	}
+	return prop
+}
+
+// validate checks a decoded JSON value against a schema. Objects must carry
+// every required property and nothing undocumented.
+func validate(t *testing.T, doc *openapi.Document, at string, schema *openapi.Schema, value interface{}) {
+	t.Helper()
+	schema = resolve(t, doc, schema)
+	if schema == nil {
+		t.Errorf("%s: no schema", at)
+		return
+	}
+	if schema.Type == "" {
+		return // anything
+	}
+	if value == nil {
+		if !schema.Nullable {
+			t.Errorf("%s: null where the schema expects %s", at, schema.Type)
+		}
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: got %T, want object", at, value)
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				t.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			prop := schema.Properties[key]
+			if prop == nil {
+				prop = schema.AdditionalProperties
+			}
+			if prop == nil {
+				t.Errorf("%s: property %q is not in the schema", at, key)
+				continue
+			}
+			validate(t, doc, at+"."+key, prop, obj[key])
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s: got %T, want array", at, value)
+			return
+		}
+		for i, item := range items {
+			validate(t, doc, fmt.Sprintf("%s[%d]", at, i), schema.Items, item)
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			t.Errorf("%s: got %T, want string", at, value)
+			return
+		}
+		switch schema.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+				t.Errorf("%s: %q is not a date-time", at, s)
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+				t.Errorf("%s: %q is not base64", at, s)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+			t.Errorf("%s: %q is not one of %v", at, s, schema.Enum)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			t.Errorf("%s: got %T, want number", at, value)
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != float64(int64(n)) {
+			t.Errorf("%s: got %v, want integer", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			t.Errorf("%s: got %T, want boolean", at, value)
+		}
+	default:
+		t.Errorf("%s: unknown schema type %q", at, schema.Type)
+	}
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleOf builds a value of typ with every JSON-encoded field set, so
+// omitempty fields are exercised as well
+func sampleOf(typ reflect.Type) reflect.Value {
+	v := reflect.New(typ).Elem()
+	fill(v, 0)
+	return v
+}
+
+func fill(v reflect.Value, depth int) {
+	if depth > 6 {
+		return
+	}
+	if v.Type() == reflect.TypeOf(time.Time{}) {
+		v.Set(reflect.ValueOf(time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)))
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(7)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(7)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(0.5)
+	case reflect.String:
+		v.SetString("sample")
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		fill(v.Elem(), depth+1)
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		fill(v.Index(0), depth+1)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fill(v.Index(i), depth+1)
+		}
+	case reflect.Map:
+		v.Set(reflect.MakeMap(v.Type()))
+		key := reflect.New(v.Type().Key()).Elem()
+		fill(key, depth+1)
+		elem := reflect.New(v.Type().Elem()).Elem()
+		fill(elem, depth+1)
+		v.SetMapIndex(key, elem)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			fill(v.Field(i), depth+1)
+		}
+	}
+}
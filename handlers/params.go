@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// parseTimeRange reads the optional "from" and "to" RFC3339 query parameters.
+// Missing values default to the window ending now.
+func parseTimeRange(r *http.Request, defaultWindow time.Duration) (time.Time, time.Time, error) {
+	to := time.Now()
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		parsed, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'to' time, expected RFC3339")
+		}
+		to = parsed
+	}
+
+	from := to.Add(-defaultWindow)
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		parsed, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'from' time, expected RFC3339")
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("'from' must be before 'to'")
+	}
+
+	return from, to, nil
+}
@@ -1,27 +1,26 @@
 package handlers
 
 import (
-	"crypto/sha256"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
+	"semantic-auth/api"
 	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/logging"
+	"semantic-auth/metrics"
 	"semantic-auth/models"
+	"semantic-auth/moderation"
 	"semantic-auth/openai"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-type RegisterRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
 func RegisterHandler(w http.ResponseWriter, r *http.Request) {
-	var req RegisterRequest
+	var req api.RegisterRequest
 	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
@@ -36,55 +35,79 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("Received registration request for:", req.Username)
+	phrases := []string{req.Password}
+	for _, phrase := range req.Phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			phrases = append(phrases, phrase)
+		}
+	}
+
+	// Keep the phrases out of anything logged for this request
+	logging.SetUsername(r.Context(), req.Username)
+	logging.AddPhrases(r.Context(), phrases...)
+
+	slog.InfoContext(r.Context(), "Received registration request")
 
 	collection := db.Client.Database("semantic_auth").Collection("users")
-	log.Println("Checking if user exists...")
 	// Check if user exists
 	count, err := collection.CountDocuments(r.Context(), bson.M{"username": req.Username})
-	log.Println("Checked user count:", count)
 	if err != nil {
-		log.Println("Database error:", err)
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
 		RespondWithError(w, http.StatusInternalServerError, "Database error occurred")
 		return
 	}
 	if count > 0 {
-		RespondWithError(w, http.StatusConflict, "User already exists")
+		RespondWithErrorCode(w, http.StatusConflict, api.CodeUserExists, "User already exists")
 		return
 	}
 
-	log.Println("Embedding password...")
-	vec, err := openai.Embed(req.Password)
+	slog.DebugContext(r.Context(), "Embedding phrases", "count", len(phrases))
+	vecs, err := openai.EmbedBatch(r.Context(), phrases)
 	if err != nil {
 		// Check if this is a moderation error
-		if strings.Contains(err.Error(), "moderation error") {
-			log.Println("Moderation error:", err)
-			RespondWithError(w, http.StatusBadRequest, err.Error())
+		var rejected *moderation.RejectedError
+		if errors.As(err, &rejected) {
+			slog.InfoContext(r.Context(), "Phrase rejected by moderation", "error", err)
+			content := req.Password
+			var batchErr *openai.BatchError
+			if errors.As(err, &batchErr) {
+				content = phrases[batchErr.Index]
+			}
+			moderation.RecordRejection(r.Context(), req.Username, content, rejected.Response)
+			RespondWithErrorCode(w, http.StatusBadRequest, api.CodeContentRejected, rejected.Error())
 			return
 		}
-		
+
 		// Other embedding errors
 		RespondWithError(w, http.StatusInternalServerError, "Failed to embed password")
-		log.Println("OpenAI error:", err)
+		slog.ErrorContext(r.Context(), "Embedding failed", "error", err)
 		return
 	}
 
-	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(strings.ToLower(req.Password))))
-
 	user := models.User{
-		Username: req.Username,
-		Hash:     hash,
-		Vector:   vec,
-		Raw:      req.Password, // optional, remove if you want to be pure
+		Username:   req.Username,
+		Hash:       models.HashPhrase(req.Password),
+		Vector:     vecs[0],
+		Model:      models.DefaultEmbeddingModel,
+		Dimensions: len(vecs[0]),
+		Raw:        req.Password, // optional, remove if you want to be pure
+	}
+	for _, vec := range vecs[1:] {
+		user.Vectors = append(user.Vectors, vec)
 	}
 
 	_, err = collection.InsertOne(r.Context(), user)
 	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
 		RespondWithError(w, http.StatusInternalServerError, "Failed to store user")
 		return
 	}
+	metrics.Registrations.Inc()
 
-	RespondWithSuccess(w, "User registered successfully", map[string]interface{}{
-		"username": req.Username,
+	events.Default.Publish(events.Event{
+		Type:     events.UserRegistered,
+		Username: req.Username,
 	})
+
+	RespondWithSuccess(w, "User registered successfully", api.RegisterResponse{Username: req.Username})
 }
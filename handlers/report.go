@@ -1,77 +1,297 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"semantic-auth/api"
 	"semantic-auth/db"
 	"semantic-auth/models"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type ReportRequest struct {
-	Username  string  `json:"username"`
-	Threshold float64 `json:"threshold,omitempty"`
+const (
+	defaultReportLimit = 50
+	maxReportLimit     = 500
+)
+
+// reportQuery holds the parsed /report filters and sort order
+type reportQuery struct {
+	threshold float64
+	filter    bson.M
+	sortField string // "timestamp" or "similarity"
+	direction int    // 1 ascending, -1 descending
+	limit     int64
+	cursor    *reportCursor
 }
 
-type ReportResponse struct {
-	Input      string    `json:"input"`
-	Similarity float64   `json:"similarity"`
-	Timestamp  time.Time `json:"timestamp"`
-	Passed     bool      `json:"passed"`
+// reportCursor is the position after the last attempt of a page
+type reportCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    string          `json:"id"`
 }
 
-func ReportHandler(w http.ResponseWriter, r *http.Request) {
-	// Get threshold from query parameter
-	thresholdStr := r.URL.Query().Get("threshold")
-	threshold := 0.88 // default threshold
+// parseReportQuery reads the filter, sort and paging query parameters shared by the report endpoints
+func parseReportQuery(r *http.Request) (*reportQuery, error) {
+	query := r.URL.Query()
+	q := &reportQuery{
+		threshold: DefaultThreshold,
+		filter:    bson.M{},
+		sortField: "timestamp",
+		direction: -1,
+		limit:     defaultReportLimit,
+	}
 
-	if thresholdStr != "" {
+	if thresholdStr := query.Get("threshold"); thresholdStr != "" {
 		parsedThreshold, err := strconv.ParseFloat(thresholdStr, 64)
 		if err == nil && parsedThreshold >= 0.5 && parsedThreshold <= 1.0 {
-			threshold = parsedThreshold
+			q.threshold = parsedThreshold
+		}
+	}
+
+	if username := strings.ToLower(strings.TrimSpace(query.Get("username"))); username != "" {
+		q.filter["username"] = username
+	}
+
+	// Time range, only applied when given
+	timeFilter := bson.M{}
+	for param, op := range map[string]string{"from": "$gte", "to": "$lte"} {
+		if value := query.Get(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid '%s' time, expected RFC3339", param)
+			}
+			timeFilter[op] = parsed
+		}
+	}
+	if len(timeFilter) > 0 {
+		q.filter["timestamp"] = timeFilter
+	}
+
+	// Similarity range and pass/fail at the threshold
+	similarityFilter := bson.M{}
+	if minStr := query.Get("min_similarity"); minStr != "" {
+		min, err := strconv.ParseFloat(minStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid min_similarity")
+		}
+		similarityFilter["$gte"] = min
+	}
+	if maxStr := query.Get("max_similarity"); maxStr != "" {
+		max, err := strconv.ParseFloat(maxStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid max_similarity")
+		}
+		similarityFilter["$lte"] = max
+	}
+	switch query.Get("passed") {
+	case "":
+	case "true":
+		if min, ok := similarityFilter["$gte"].(float64); !ok || min < q.threshold {
+			similarityFilter["$gte"] = q.threshold
+		}
+	case "false":
+		similarityFilter["$lt"] = q.threshold
+	default:
+		return nil, fmt.Errorf("passed must be 'true' or 'false'")
+	}
+	if len(similarityFilter) > 0 {
+		q.filter["similarity"] = similarityFilter
+	}
+
+	switch sort := query.Get("sort"); sort {
+	case "", "timestamp", "similarity":
+		if sort != "" {
+			q.sortField = sort
+		}
+	default:
+		return nil, fmt.Errorf("sort must be 'timestamp' or 'similarity'")
+	}
+
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.direction = 1
+	default:
+		return nil, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || limit <= 0 || limit > maxReportLimit {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxReportLimit)
 		}
+		q.limit = limit
 	}
 
-	// Get username from query parameter (optional)
-	username := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("username")))
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := decodeReportCursor(cursorStr)
+		if err != nil || cursor.Sort != q.sortField {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		q.cursor = cursor
+	}
+
+	return q, nil
+}
+
+// pageFilter adds the cursor position to the filter, keyed on (sort field, _id)
+func (q *reportQuery) pageFilter() (bson.M, error) {
+	if q.cursor == nil {
+		return q.filter, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(q.cursor.ID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var value interface{}
+	if q.sortField == "timestamp" {
+		var ts time.Time
+		if err := json.Unmarshal(q.cursor.Value, &ts); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		value = ts
+	} else {
+		var similarity float64
+		if err := json.Unmarshal(q.cursor.Value, &similarity); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		value = similarity
+	}
+
+	op := "$lt"
+	if q.direction == 1 {
+		op = "$gt"
+	}
+
+	return bson.M{"$and": bson.A{
+		q.filter,
+		bson.M{"$or": bson.A{
+			bson.M{q.sortField: bson.M{op: value}},
+			bson.M{q.sortField: value, "_id": bson.M{op: id}},
+		}},
+	}}, nil
+}
+
+// findOptions sorts by the requested field with _id as a tiebreaker
+func (q *reportQuery) findOptions() *options.FindOptions {
+	return options.Find().SetSort(bson.D{
+		{Key: q.sortField, Value: q.direction},
+		{Key: "_id", Value: q.direction},
+	})
+}
+
+// nextCursor encodes the position after attempt
+func (q *reportQuery) nextCursor(attempt models.LoginAttempt) string {
+	var value interface{} = attempt.Timestamp
+	if q.sortField == "similarity" {
+		value = attempt.Similarity
+	}
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(reportCursor{Sort: q.sortField, Value: raw, ID: attempt.ID.Hex()})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeReportCursor(s string) (*reportCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var cursor reportCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// redactedInput replaces attempted phrases when REPORT_REDACT_INPUT is set
+const redactedInput = "[redacted]"
+
+// toReportResponse converts an attempt for output, applying the redaction policy
+func toReportResponse(attempt models.LoginAttempt, threshold float64) api.ReportResponse {
+	input := attempt.Input
+	if redactReportInputs {
+		input = redactedInput
+	}
+
+	return api.ReportResponse{
+		ID:         attempt.ID.Hex(),
+		Username:   attempt.Username,
+		Input:      input,
+		Similarity: attempt.Similarity,
+		Timestamp:  attempt.Timestamp,
+		Passed:     attempt.Similarity >= threshold,
+	}
+}
+
+func ReportHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseReportQuery(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, err := q.pageFilter()
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	coll := db.Client.Database("semantic_auth").Collection("login_attempts")
 
-	// Create filter based on whether username is provided
-	filter := bson.M{}
-	if username != "" {
-		filter["username"] = username
+	total, err := coll.CountDocuments(r.Context(), q.filter)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to count login attempts")
+		return
 	}
 
-	cursor, err := coll.Find(r.Context(),
-		filter,
-		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}}).SetLimit(50),
-	)
+	// Fetch one extra row to know whether there is another page
+	cursor, err := coll.Find(r.Context(), filter, q.findOptions().SetLimit(q.limit+1))
 	if err != nil {
 		RespondWithError(w, http.StatusInternalServerError, "Failed to query login attempts")
 		return
 	}
 	defer cursor.Close(r.Context())
 
-	var results []ReportResponse
+	page := api.ReportPage{
+		Attempts: []api.ReportResponse{},
+		Total:    total,
+	}
+	var last models.LoginAttempt
 	for cursor.Next(r.Context()) {
 		var attempt models.LoginAttempt
 		if err := cursor.Decode(&attempt); err != nil {
-			continue
+			// A skipped row would leave a short page that disagrees with Total
+			slog.ErrorContext(r.Context(), "Failed to decode login attempt", "error", err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to read login attempts")
+			return
 		}
 
-		results = append(results, ReportResponse{
-			Input:      attempt.Input,
-			Similarity: attempt.Similarity,
-			Timestamp:  attempt.Timestamp,
-			Passed:     attempt.Similarity >= threshold,
-		})
+		if int64(len(page.Attempts)) == q.limit {
+			page.NextCursor = q.nextCursor(last)
+			break
+		}
+
+		page.Attempts = append(page.Attempts, toReportResponse(attempt, q.threshold))
+		last = attempt
+	}
+	if err := cursor.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "Report cursor failed", "error", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read login attempts")
+		return
 	}
 
-	RespondWithSuccess(w, "Login attempts retrieved successfully", results)
+	RespondWithSuccess(w, "Login attempts retrieved successfully", page)
 }
@@ -3,19 +3,35 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+
+	"semantic-auth/api"
 )
 
-// StandardResponse is a consistent response structure for all API endpoints
-type StandardResponse struct {
-	Status  string      `json:"status"`  // "success" or "error"
-	Success bool        `json:"success"` // true or false
-	Message string      `json:"message,omitempty"` // optional message
-	Data    interface{} `json:"data,omitempty"`    // payload data
+// codeForStatus is the default error code for an HTTP status
+func codeForStatus(statusCode int) string {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return api.CodeInvalidRequest
+	case http.StatusUnauthorized:
+		return api.CodeUnauthorized
+	case http.StatusForbidden:
+		return api.CodeForbidden
+	case http.StatusNotFound:
+		return api.CodeNotFound
+	case http.StatusConflict:
+		return api.CodeConflict
+	case http.StatusLocked:
+		return api.CodeAccountLocked
+	case http.StatusServiceUnavailable:
+		return api.CodeUnavailable
+	default:
+		return api.CodeInternal
+	}
 }
 
 // RespondWithSuccess sends a standardized success response with data
 func RespondWithSuccess(w http.ResponseWriter, message string, data interface{}) {
-	response := StandardResponse{
+	response := api.StandardResponse{
 		Status:  "success",
 		Success: true,
 		Message: message,
@@ -27,12 +43,18 @@ func RespondWithSuccess(w http.ResponseWriter, message string, data interface{})
 	json.NewEncoder(w).Encode(response)
 }
 
-// RespondWithError sends a standardized error response
+// RespondWithError sends a standardized error response with the code for statusCode
 func RespondWithError(w http.ResponseWriter, statusCode int, message string) {
-	response := StandardResponse{
+	RespondWithErrorCode(w, statusCode, codeForStatus(statusCode), message)
+}
+
+// RespondWithErrorCode sends a standardized error response with a specific code
+func RespondWithErrorCode(w http.ResponseWriter, statusCode int, code, message string) {
+	response := api.StandardResponse{
 		Status:  "error",
 		Success: false,
 		Message: message,
+		Code:    code,
 	}
 	
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"net/http"
+
+	"semantic-auth/analysis"
+	"semantic-auth/api"
+	"semantic-auth/models"
+	"semantic-auth/openapi"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Route is one endpoint of the versioned API. The route table both mounts
+// the handlers and generates the OpenAPI document, so the published
+// specification cannot list a route the server doesn't serve or miss one it does.
+type Route struct {
+	Method    string
+	Path      string // relative to api.Prefix, with {name} path parameters
+	Handler   http.HandlerFunc
+	ID        string // OpenAPI operationId
+	Summary   string
+	Tag       string
+	Admin     bool // requires the admin bearer token
+	Session   bool // requires a session bearer token
+	Query     []openapi.Parameter
+	Request   interface{} // JSON request body, nil for none
+	Response  interface{} // data of the success response, nil for none
+	MediaType []string    // success media types other than the JSON envelope
+	Errors    []int       // error statuses the handler answers with
+}
+
+// reportParams are the filters shared by /report and /report/export
+var reportParams = []openapi.Parameter{
+	queryParam("username", "string", "Only this user's attempts"),
+	queryParam("threshold", "number", "Threshold deciding passed (0.5 to 1, default LOGIN_THRESHOLD)"),
+	queryParam("from", "date-time", "Earliest attempt time"),
+	queryParam("to", "date-time", "Latest attempt time"),
+	queryParam("min_similarity", "number", "Minimum similarity"),
+	queryParam("max_similarity", "number", "Maximum similarity"),
+	enumParam("passed", "Only attempts that passed or failed at the threshold", "true", "false"),
+	enumParam("sort", "Sort field (default timestamp)", "timestamp", "similarity"),
+	enumParam("order", "Sort order (default desc)", "asc", "desc"),
+}
+
+// timeRangeParams are the optional window of the aggregate endpoints
+var timeRangeParams = []openapi.Parameter{
+	queryParam("from", "date-time", "Start of the window"),
+	queryParam("to", "date-time", "End of the window (default now)"),
+}
+
+// Routes is the versioned API
+var Routes = []Route{
+	{
+		Method: http.MethodPost, Path: "/register", Handler: RegisterHandler,
+		ID: "register", Summary: "Register a user with a passphrase and optional additional phrases", Tag: "auth",
+		Request: api.RegisterRequest{}, Response: api.RegisterResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/login", Handler: LoginHandler,
+		ID: "login", Summary: "Log in with a phrase semantically similar to an enrolled one", Tag: "auth",
+		Request: api.LoginRequest{}, Response: api.LoginResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusLocked, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/sessions/introspect", Handler: IntrospectHandler,
+		ID: "introspectSession", Summary: "Look up the user and login time of a session token", Tag: "sessions",
+		Request: api.IntrospectRequest{}, Response: api.SessionResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/logout", Handler: LogoutHandler,
+		ID: "logout", Summary: "Revoke the session in the Authorization header", Tag: "sessions", Session: true,
+		Errors: []int{http.StatusUnauthorized, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/report", Handler: ReportHandler,
+		ID: "getReport", Summary: "Page through login attempts", Tag: "reports", Admin: true,
+		Query: append(append([]openapi.Parameter{}, reportParams...),
+			queryParam("limit", "integer", "Page size (1 to 500, default 50)"),
+			queryParam("cursor", "string", "next_cursor from the previous page"),
+		),
+		Response: api.ReportPage{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/report/simulate", Handler: SimulateHandler,
+		ID: "simulateThresholds", Summary: "Replay attempts against candidate thresholds", Tag: "reports", Admin: true,
+		Query: append([]openapi.Parameter{
+			requiredParam(queryParam("thresholds", "string", "Comma-separated thresholds, at most 50")),
+			queryParam("username", "string", "Only this user's attempts"),
+		}, timeRangeParams...),
+		Response: SimulationResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/report/export", Handler: ExportHandler,
+		ID: "exportReport", Summary: "Export every matching login attempt as CSV or newline-delimited JSON", Tag: "reports", Admin: true,
+		Query: append(append([]openapi.Parameter{}, reportParams...),
+			enumParam("format", "Export format (default from Accept, else csv)", "csv", "ndjson"),
+		),
+		Response: api.ReportResponse{}, MediaType: []string{"text/csv", "application/x-ndjson"},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/report/stream", Handler: StreamHandler,
+		ID: "streamReport", Summary: "Stream login attempts as Server-Sent Events", Tag: "reports", Admin: true,
+		Query:    []openapi.Parameter{queryParam("username", "string", "Only this user's attempts")},
+		Response: api.ReportResponse{}, MediaType: []string{"text/event-stream"},
+		Errors: []int{http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/stats", Handler: StatsHandler,
+		ID: "getStats", Summary: "Aggregate login attempts into counts, a histogram and a time series", Tag: "reports", Admin: true,
+		Query: append([]openapi.Parameter{
+			queryParam("username", "string", "Only this user's attempts"),
+			queryParam("threshold", "number", "Threshold deciding passed (default LOGIN_THRESHOLD)"),
+			queryParam("bin_width", "number", "Histogram bin width (default 0.05)"),
+			enumParam("bucket", "Series bucket size (default day)", "hour", "day"),
+		}, timeRangeParams...),
+		Response: api.StatsResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/moderation", Handler: ModerationStatsHandler,
+		ID: "getModerationStats", Summary: "Count moderation rejections by category", Tag: "admin", Admin: true,
+		Query:    timeRangeParams,
+		Response: ModerationStatsResponse{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/attempts/{id}/label", Handler: LabelAttemptHandler,
+		ID: "labelAttempt", Summary: "Label a login attempt as genuine or impostor", Tag: "admin", Admin: true,
+		Request: LabelRequest{}, Response: LabelResponse{},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/calibration", Handler: CalibrationHandler,
+		ID: "calibrate", Summary: "Compute error rates and the equal-error-rate threshold from labeled attempts", Tag: "admin", Admin: true,
+		Query: append([]openapi.Parameter{
+			queryParam("username", "string", "Only this user's attempts"),
+			enumParam("infer", "Infer labels for unlabeled attempts", "true", "false"),
+			queryParam("window", "string", "Inference window as a Go duration (default 10m)"),
+			enumParam("per_user", "Also calibrate each user", "true", "false"),
+			queryParam("step", "number", "Threshold spacing on the curve (default 0.01)"),
+		}, timeRangeParams...),
+		Response: analysis.CalibrationReport{},
+		Errors:   []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/webhooks", Handler: ListWebhooksHandler,
+		ID: "listWebhooks", Summary: "List webhook subscriptions", Tag: "admin", Admin: true,
+		Response: []models.WebhookSubscription{},
+		Errors:   []int{http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodPost, Path: "/admin/webhooks", Handler: CreateWebhookHandler,
+		ID: "createWebhook", Summary: "Subscribe a URL to authentication events", Tag: "admin", Admin: true,
+		Request: WebhookRequest{}, Response: models.WebhookSubscription{},
+		Errors: []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodDelete, Path: "/admin/webhooks/{id}", Handler: DeleteWebhookHandler,
+		ID: "deleteWebhook", Summary: "Remove a webhook subscription", Tag: "admin", Admin: true,
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	{
+		Method: http.MethodGet, Path: "/admin/webhooks/dead-letters", Handler: DeadLettersHandler,
+		ID: "listDeadLetters", Summary: "List the most recent deliveries that failed every retry", Tag: "admin", Admin: true,
+		Response: []models.WebhookDeadLetter{},
+		Errors:   []int{http.StatusInternalServerError},
+	},
+}
+
+// Mount registers Routes and the OpenAPI document on r, which is mounted at api.Prefix
+func Mount(r chi.Router) {
+	r.Get("/openapi.json", OpenAPIHandler)
+
+	for _, route := range Routes {
+		if !route.Admin {
+			r.Method(route.Method, route.Path, route.Handler)
+		}
+	}
+
+	// Admin routes, protected by ADMIN_TOKEN
+	r.Group(func(r chi.Router) {
+		r.Use(AdminAuth)
+		for _, route := range Routes {
+			if route.Admin {
+				r.Method(route.Method, route.Path, route.Handler)
+			}
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log/slog"
+
+	"semantic-auth/config"
+	"semantic-auth/models"
+	"semantic-auth/utils"
+)
+
+var (
+	// DefaultThreshold is the login threshold when a request does not give one
+	DefaultThreshold = 0.88
+
+	// defaultScorer and defaultAggregator apply to users without their own
+	defaultScorer     utils.Scorer     = utils.CosineScorer{}
+	defaultAggregator utils.Aggregator = utils.MaxAggregator{}
+
+	// redactReportInputs hides attempted phrases from every report output
+	redactReportInputs = false
+)
+
+// Initialize applies the deployment-wide scoring, lockout, report, readiness
+// and admin settings. Names were validated when the configuration was loaded.
+func Initialize(cfg *config.Config) {
+	DefaultThreshold = cfg.Scoring.Threshold
+	defaultScorer, _ = utils.ScorerByName(cfg.Scoring.Scorer)
+	defaultAggregator, _ = utils.AggregatorByName(cfg.Scoring.Aggregator)
+
+	lockoutAttempts = cfg.Lockout.Attempts
+	lockoutDuration = cfg.Lockout.Duration
+
+	redactReportInputs = cfg.Report.RedactInput
+
+	criticalDependencies = make(map[string]bool, len(cfg.Readiness.Critical))
+	for _, name := range cfg.Readiness.Critical {
+		criticalDependencies[name] = true
+	}
+
+	adminToken = cfg.Admin.Token
+
+	slog.Info("Similarity scoring", "threshold", DefaultThreshold, "scorer", defaultScorer.Name(), "aggregator", defaultAggregator.Name())
+}
+
+// scoringFor returns the user's own scorer and aggregator, falling back to the deployment defaults
+func scoringFor(user models.User) (utils.Scorer, utils.Aggregator) {
+	scorer, aggregator, err := utils.ScoringFor(user.Scorer, user.Aggregator, defaultScorer, defaultAggregator)
+	if err != nil {
+		slog.Warn("User has invalid scoring", "username", user.Username, "error", err, "scorer", scorer.Name(), "aggregator", aggregator.Name())
+	}
+	return scorer, aggregator
+}
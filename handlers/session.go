@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"semantic-auth/api"
+	"semantic-auth/sessions"
+)
+
+// IntrospectHandler reports who a session token belongs to, for services
+// that accept the tokens. Unknown, revoked and expired tokens get 401.
+func IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	var req api.IntrospectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	session, err := sessions.Lookup(r.Context(), req.Token)
+	if errors.Is(err, sessions.ErrInvalid) {
+		RespondWithErrorCode(w, http.StatusUnauthorized, api.CodeInvalidSession, "Invalid or expired session")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to look up session", "error", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to look up session")
+		return
+	}
+
+	RespondWithSuccess(w, "Session is active", sessions.Response(session))
+}
+
+// LogoutHandler revokes the session whose token is in the Authorization
+// header. Logging out twice succeeds.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	token := api.BearerToken(r)
+	if token == "" {
+		RespondWithErrorCode(w, http.StatusUnauthorized, api.CodeInvalidSession, "Missing session token")
+		return
+	}
+
+	if err := sessions.Revoke(r.Context(), token); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to revoke session", "error", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+
+	RespondWithSuccess(w, "Logged out successfully", nil)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"semantic-auth/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxSimulatedThresholds caps how many candidates one request can evaluate
+const maxSimulatedThresholds = 50
+
+type SimulationCounts struct {
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+}
+
+type ThresholdSimulation struct {
+	Threshold float64                     `json:"threshold"`
+	Overall   SimulationCounts            `json:"overall"`
+	Users     map[string]SimulationCounts `json:"users"`
+}
+
+type SimulationResponse struct {
+	From     time.Time             `json:"from"`
+	To       time.Time             `json:"to"`
+	Attempts int                   `json:"attempts"`
+	Results  []ThresholdSimulation `json:"results"`
+}
+
+// SimulateHandler replays every attempt in a time window against candidate
+// thresholds, so a policy change can be previewed before rolling it out
+func SimulateHandler(w http.ResponseWriter, r *http.Request) {
+	thresholdsStr := r.URL.Query().Get("thresholds")
+	if thresholdsStr == "" {
+		RespondWithError(w, http.StatusBadRequest, "Missing thresholds")
+		return
+	}
+
+	var thresholds []float64
+	for _, part := range strings.Split(thresholdsStr, ",") {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || threshold < -1 || threshold > 1 {
+			RespondWithError(w, http.StatusBadRequest, "Thresholds must be numbers between -1 and 1")
+			return
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	if len(thresholds) > maxSimulatedThresholds {
+		RespondWithError(w, http.StatusBadRequest, "Too many thresholds")
+		return
+	}
+	sort.Float64s(thresholds)
+
+	from, to, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter := bson.M{"timestamp": bson.M{"$gte": from, "$lte": to}}
+	if username := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("username"))); username != "" {
+		filter["username"] = username
+	}
+
+	coll := db.Client.Database("semantic_auth").Collection("login_attempts")
+	cursor, err := coll.Find(r.Context(), filter,
+		options.Find().SetProjection(bson.M{"username": 1, "similarity": 1}),
+	)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to query login attempts")
+		return
+	}
+	defer cursor.Close(r.Context())
+
+	resp := SimulationResponse{
+		From:    from,
+		To:      to,
+		Results: make([]ThresholdSimulation, len(thresholds)),
+	}
+	for i, threshold := range thresholds {
+		resp.Results[i] = ThresholdSimulation{
+			Threshold: threshold,
+			Users:     map[string]SimulationCounts{},
+		}
+	}
+
+	for cursor.Next(r.Context()) {
+		var attempt struct {
+			Username   string  `bson:"username"`
+			Similarity float64 `bson:"similarity"`
+		}
+		if err := cursor.Decode(&attempt); err != nil {
+			// Skipping it would understate the counts without saying so
+			RespondWithError(w, http.StatusInternalServerError, "Failed to read login attempts")
+			return
+		}
+		resp.Attempts++
+
+		for i := range resp.Results {
+			result := &resp.Results[i]
+			counts := result.Users[attempt.Username]
+			if attempt.Similarity >= result.Threshold {
+				counts.Passed++
+				result.Overall.Passed++
+			} else {
+				counts.Failed++
+				result.Overall.Failed++
+			}
+			result.Users[attempt.Username] = counts
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read login attempts")
+		return
+	}
+
+	RespondWithSuccess(w, "Threshold simulation completed successfully", resp)
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// statsGroup is the shape of each $group stage in the stats pipeline
+type statsGroup struct {
+	ID             interface{} `bson:"_id"`
+	Attempts       int64       `bson:"attempts"`
+	Passed         int64       `bson:"passed"`
+	MeanSimilarity float64     `bson:"mean_similarity"`
+}
+
+func (g statsGroup) passRate() float64 {
+	if g.Attempts == 0 {
+		return 0
+	}
+	return float64(g.Passed) / float64(g.Attempts)
+}
+
+// StatsHandler aggregates login attempts into global and per-user counts,
+// a similarity histogram and a time-bucketed series
+func StatsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	from, to, err := parseTimeRange(r, 7*24*time.Hour)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	threshold := DefaultThreshold
+	if thresholdStr := query.Get("threshold"); thresholdStr != "" {
+		threshold, err = strconv.ParseFloat(thresholdStr, 64)
+		if err != nil || threshold < -1 || threshold > 1 {
+			RespondWithError(w, http.StatusBadRequest, "Threshold must be between -1 and 1")
+			return
+		}
+	}
+
+	binWidth := 0.05
+	if binStr := query.Get("bin_width"); binStr != "" {
+		binWidth, err = strconv.ParseFloat(binStr, 64)
+		if err != nil || binWidth < 0.001 || binWidth > 1 {
+			RespondWithError(w, http.StatusBadRequest, "bin_width must be between 0.001 and 1")
+			return
+		}
+	}
+
+	bucket := query.Get("bucket")
+	switch bucket {
+	case "":
+		bucket = "day"
+	case "hour", "day":
+	default:
+		RespondWithError(w, http.StatusBadRequest, "bucket must be 'hour' or 'day'")
+		return
+	}
+
+	match := bson.M{"timestamp": bson.M{"$gte": from, "$lte": to}}
+	if username := strings.ToLower(strings.TrimSpace(query.Get("username"))); username != "" {
+		match["username"] = username
+	}
+
+	// Counts shared by every grouping
+	counts := func(id interface{}) bson.M {
+		return bson.M{"$group": bson.M{
+			"_id":             id,
+			"attempts":        bson.M{"$sum": 1},
+			"passed":          bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$similarity", threshold}}, 1, 0}}},
+			"mean_similarity": bson.M{"$avg": "$similarity"},
+		}}
+	}
+
+	// The epsilon keeps values that sit exactly on a bin edge out of the bin below
+	binIndex := bson.M{"$floor": bson.M{"$add": bson.A{bson.M{"$divide": bson.A{"$similarity", binWidth}}, 1e-9}}}
+
+	pipeline := bson.A{
+		bson.M{"$match": match},
+		bson.M{"$facet": bson.M{
+			"global": bson.A{counts(nil)},
+			"users": bson.A{
+				counts("$username"),
+				bson.M{"$sort": bson.D{{Key: "attempts", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"histogram": bson.A{
+				counts(binIndex),
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"series": bson.A{
+				counts(bson.M{"$dateTrunc": bson.M{"date": "$timestamp", "unit": bucket, "timezone": "UTC"}}),
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+		}},
+	}
+
+	coll := db.Client.Database("semantic_auth").Collection("login_attempts")
+	cursor, err := coll.Aggregate(r.Context(), pipeline)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to aggregate login attempts")
+		return
+	}
+	defer cursor.Close(r.Context())
+
+	var facets []struct {
+		Global    []statsGroup `bson:"global"`
+		Users     []statsGroup `bson:"users"`
+		Histogram []statsGroup `bson:"histogram"`
+		Series    []statsGroup `bson:"series"`
+	}
+	if err := cursor.All(r.Context(), &facets); err != nil || len(facets) != 1 {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read login statistics")
+		return
+	}
+	facet := facets[0]
+
+	resp := api.StatsResponse{
+		From:      from,
+		To:        to,
+		Threshold: threshold,
+		BinWidth:  binWidth,
+		Bucket:    bucket,
+		Users:     []api.StatsCounts{},
+		Histogram: []api.HistogramBin{},
+		Series:    []api.SeriesPoint{},
+	}
+
+	if len(facet.Global) == 1 {
+		g := facet.Global[0]
+		resp.Global = api.StatsCounts{
+			Attempts:       g.Attempts,
+			Passed:         g.Passed,
+			PassRate:       g.passRate(),
+			MeanSimilarity: g.MeanSimilarity,
+		}
+	}
+
+	for _, g := range facet.Users {
+		username, _ := g.ID.(string)
+		resp.Users = append(resp.Users, api.StatsCounts{
+			Username:       username,
+			Attempts:       g.Attempts,
+			Passed:         g.Passed,
+			PassRate:       g.passRate(),
+			MeanSimilarity: g.MeanSimilarity,
+		})
+	}
+
+	for _, g := range facet.Histogram {
+		index, ok := g.ID.(float64)
+		if !ok {
+			continue
+		}
+		resp.Histogram = append(resp.Histogram, api.HistogramBin{
+			Start: roundBin(index * binWidth),
+			End:   roundBin((index + 1) * binWidth),
+			Count: g.Attempts,
+		})
+	}
+
+	for _, g := range facet.Series {
+		start, ok := g.ID.(primitive.DateTime)
+		if !ok {
+			continue
+		}
+		resp.Series = append(resp.Series, api.SeriesPoint{
+			Start:    start.Time().UTC(),
+			Attempts: g.Attempts,
+			Passed:   g.Passed,
+			PassRate: g.passRate(),
+		})
+	}
+
+	RespondWithSuccess(w, "Login statistics retrieved successfully", resp)
+}
+
+// roundBin trims floating point noise from histogram edges
+func roundBin(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
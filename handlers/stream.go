@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"semantic-auth/events"
+	"semantic-auth/models"
+)
+
+const (
+	// streamHeartbeat keeps idle connections open through proxies
+	streamHeartbeat = 15 * time.Second
+
+	// streamBuffer is how many events a slow client may fall behind before missing some
+	streamBuffer = 64
+)
+
+// streamsClosing is closed when the server shuts down, ending every stream
+// so it doesn't hold up draining
+var (
+	streamsClosing = make(chan struct{})
+	closeStreams   sync.Once
+)
+
+// CloseStreams ends all open event streams. Register it with
+// http.Server.RegisterOnShutdown.
+func CloseStreams() {
+	closeStreams.Do(func() { close(streamsClosing) })
+}
+
+// disableWriteTimeout lifts the server write timeout for a long-lived response.
+// Writers that don't support deadlines keep the server timeout.
+func disableWriteTimeout(w http.ResponseWriter) {
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// StreamHandler pushes login attempts to the client as Server-Sent Events,
+// optionally filtered to one username, with periodic heartbeat comments
+func StreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, "Streaming is not supported")
+		return
+	}
+
+	username := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("username")))
+
+	sub := events.Default.Subscribe("stream", streamBuffer, nil)
+	defer sub.Close()
+
+	disableWriteTimeout(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-streamsClosing:
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Type != events.LoginSucceeded && event.Type != events.LoginFailed {
+				continue
+			}
+			if username != "" && event.Username != username {
+				continue
+			}
+			attempt, ok := event.Data.(models.LoginAttempt)
+			if !ok {
+				continue
+			}
+
+			threshold := attempt.Threshold
+			if threshold == 0 {
+				threshold = DefaultThreshold
+			}
+			data, err := json.Marshal(toReportResponse(attempt, threshold))
+			if err != nil {
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: attempt\nid: %s\ndata: %s\n\n", attempt.ID.Hex(), data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
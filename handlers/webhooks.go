@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/models"
+	"semantic-auth/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"` // empty subscribes to every event
+	Secret string   `json:"secret,omitempty"`
+}
+
+// CreateWebhookHandler registers a webhook subscription. The signing secret
+// is returned only in this response.
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		RespondWithError(w, http.StatusBadRequest, "URL must be an absolute http or https URL")
+		return
+	}
+
+	for _, name := range req.Events {
+		known := false
+		for _, t := range events.Types {
+			if string(t) == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			RespondWithError(w, http.StatusBadRequest, "Unknown event type: "+name)
+			return
+		}
+	}
+
+	if req.Secret == "" {
+		req.Secret, err = webhooks.NewSecret()
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, "Failed to generate secret")
+			return
+		}
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+
+	subscription := models.WebhookSubscription{
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    req.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	result, err := db.Client.Database("semantic_auth").Collection("webhook_subscriptions").
+		InsertOne(r.Context(), subscription)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to store webhook")
+		return
+	}
+	subscription.ID, _ = result.InsertedID.(primitive.ObjectID)
+	webhooks.Invalidate()
+
+	RespondWithSuccess(w, "Webhook created successfully", subscription)
+}
+
+// ListWebhooksHandler lists webhook subscriptions without their secrets
+func ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, err := db.Client.Database("semantic_auth").Collection("webhook_subscriptions").
+		Find(r.Context(), bson.M{}, options.Find().SetProjection(bson.M{"secret": 0}))
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to query webhooks")
+		return
+	}
+
+	subscriptions := []models.WebhookSubscription{}
+	if err := cursor.All(r.Context(), &subscriptions); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read webhooks")
+		return
+	}
+
+	RespondWithSuccess(w, "Webhooks retrieved successfully", subscriptions)
+}
+
+// DeleteWebhookHandler removes a webhook subscription
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid webhook ID")
+		return
+	}
+
+	result, err := db.Client.Database("semantic_auth").Collection("webhook_subscriptions").
+		DeleteOne(r.Context(), bson.M{"_id": id})
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+	if result.DeletedCount == 0 {
+		RespondWithError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	webhooks.Invalidate()
+
+	RespondWithSuccess(w, "Webhook deleted successfully", nil)
+}
+
+// DeadLettersHandler lists the most recent deliveries that failed every retry
+func DeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	cursor, err := db.Client.Database("semantic_auth").Collection("webhook_dead_letters").
+		Find(r.Context(), bson.M{}, options.Find().SetSort(bson.D{{Key: "failed_at", Value: -1}}).SetLimit(100))
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to query dead letters")
+		return
+	}
+
+	letters := []models.WebhookDeadLetter{}
+	if err := cursor.All(r.Context(), &letters); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, "Failed to read dead letters")
+		return
+	}
+
+	RespondWithSuccess(w, "Dead letters retrieved successfully", letters)
+}
@@ -0,0 +1,65 @@
+// Package testvec generates embedding-like vectors for tests that measure
+// scoring and storage accuracy
+package testvec
+
+import (
+	"math"
+	"math/rand"
+)
+
+// EmbeddingLike returns a random unit vector shaped like a text embedding:
+// small Gaussian components, with a few dimensions boosted by outlier. Real
+// embeddings have such dimensions; they set the int8 scale and so drive its
+// quantization error. An outlier of 1 gives an isotropic vector.
+func EmbeddingLike(rng *rand.Rand, dims int, outlier float64) []float64 {
+	v := make([]float64, dims)
+	for i := range v {
+		v[i] = rng.NormFloat64()
+	}
+	for _, i := range []int{7, 300, 1000} {
+		if i < dims {
+			v[i] *= outlier
+		}
+	}
+	return Normalize(v)
+}
+
+// NearDuplicate perturbs v so the pair's cosine similarity lands around cos,
+// like a paraphrase of the same passphrase
+func NearDuplicate(rng *rand.Rand, v []float64, cos float64) []float64 {
+	noise := make([]float64, len(v))
+	for i := range noise {
+		noise[i] = rng.NormFloat64()
+	}
+	noise = Normalize(noise)
+
+	// Mixing a unit vector with independent unit noise at angle θ gives cosine ≈ cos θ
+	theta := math.Acos(cos)
+	out := make([]float64, len(v))
+	for i := range v {
+		out[i] = math.Cos(theta)*v[i] + math.Sin(theta)*noise[i]
+	}
+	return Normalize(out)
+}
+
+// Normalize scales v to unit length in place and returns it
+func Normalize(v []float64) []float64 {
+	var mag float64
+	for _, f := range v {
+		mag += f * f
+	}
+	mag = math.Sqrt(mag)
+	for i := range v {
+		v[i] /= mag
+	}
+	return v
+}
+
+// Float32 converts v to float32
+func Float32(v []float64) []float32 {
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = float32(f)
+	}
+	return out
+}
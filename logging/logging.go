@@ -0,0 +1,37 @@
+package logging
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"semantic-auth/config"
+)
+
+// Initialize installs a JSON slog logger as the default, at the configured
+// level. Output from the standard log package is routed through it as well,
+// so it is redacted the same way.
+func Initialize(cfg config.Log) {
+	InitializeTo(os.Stdout, cfg)
+}
+
+// InitializeTo is Initialize writing to w, e.g. stderr for command-line tools
+// whose output goes to stdout
+func InitializeTo(w io.Writer, cfg config.Log) {
+	level := slog.LevelInfo
+	// The level was validated when the configuration was loaded
+	_ = level.UnmarshalText([]byte(strings.ToUpper(cfg.Level)))
+
+	json := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		// Durations read better as "1.5s" than as nanoseconds
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if attr.Value.Kind() == slog.KindDuration {
+				return slog.String(attr.Key, attr.Value.Duration().String())
+			}
+			return attr
+		},
+	})
+	slog.SetDefault(slog.New(NewRedactingHandler(json)))
+}
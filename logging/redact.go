@@ -0,0 +1,131 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces any value that may hold phrase text
+const Redacted = "[redacted]"
+
+// sensitiveKeys are attribute keys whose values are always redacted,
+// whatever they contain
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"phrase":        true,
+	"phrases":       true,
+	"input":         true,
+	"content":       true,
+	"raw":           true,
+	"secret":        true,
+	"token":         true,
+	"authorization": true,
+}
+
+// RedactingHandler wraps another handler and removes phrase text before
+// records reach it. Values under sensitive keys are always replaced, and any
+// phrase registered on the request with AddPhrases is scrubbed from the
+// message and every string, error or other value, so phrases echoed back in
+// upstream error messages are caught too. It also adds the request fields
+// recorded by Middleware.
+type RedactingHandler struct {
+	next slog.Handler
+}
+
+// NewRedactingHandler wraps next with redaction
+func NewRedactingHandler(next slog.Handler) *RedactingHandler {
+	return &RedactingHandler{next: next}
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	state := stateFrom(ctx)
+	scrubber := state.scrubber()
+
+	out := slog.NewRecord(record.Time, record.Level, scrub(record.Message, scrubber), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		out.AddAttrs(redactAttr(attr, scrubber))
+		return true
+	})
+	if state != nil {
+		out.AddAttrs(state.attrs()...)
+	}
+
+	return h.next.Handle(ctx, out)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr, nil)
+	}
+	return &RedactingHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr redacts sensitive keys and scrubs registered phrases from attr,
+// descending into groups
+func redactAttr(attr slog.Attr, scrubber *regexp.Regexp) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member, scrubber)
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		return slog.String(attr.Key, scrub(attr.Value.String(), scrubber))
+	case slog.KindAny:
+		// Errors and structs are flattened to text so their contents can be scrubbed
+		if scrubber == nil {
+			if err, ok := attr.Value.Any().(error); ok {
+				return slog.String(attr.Key, err.Error())
+			}
+			return attr
+		}
+		return slog.String(attr.Key, scrub(fmt.Sprint(attr.Value.Any()), scrubber))
+	default:
+		return attr
+	}
+}
+
+// scrub replaces every case-insensitive occurrence of a registered phrase in s
+func scrub(s string, scrubber *regexp.Regexp) string {
+	if scrubber == nil {
+		return s
+	}
+	return scrubber.ReplaceAllString(s, Redacted)
+}
+
+// phraseScrubber compiles a case-insensitive matcher for the given phrases,
+// or returns nil if there are none
+func phraseScrubber(phrases []string) *regexp.Regexp {
+	var patterns []string
+	for _, phrase := range phrases {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			patterns = append(patterns, regexp.QuoteMeta(phrase))
+		}
+	}
+	if len(patterns) == 0 {
+		return nil
+	}
+	// Longest first, so a phrase containing another is replaced whole
+	sort.Slice(patterns, func(i, j int) bool { return len(patterns[i]) > len(patterns[j]) })
+	return regexp.MustCompile("(?i)" + strings.Join(patterns, "|"))
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// requestState holds the fields added to every record logged with a
+// request's context. Handlers fill in the username and phrases as they learn them.
+type requestState struct {
+	mu       sync.Mutex
+	id       string
+	method   string
+	routing  *chi.Context
+	username string
+	phrases  []string
+	compiled *regexp.Regexp
+}
+
+type stateKey struct{}
+
+func stateFrom(ctx context.Context) *requestState {
+	if ctx == nil {
+		return nil
+	}
+	state, _ := ctx.Value(stateKey{}).(*requestState)
+	return state
+}
+
+func (s *requestState) scrubber() *regexp.Regexp {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compiled
+}
+
+func (s *requestState) attrs() []slog.Attr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := []slog.Attr{slog.String("request_id", s.id), slog.String("method", s.method)}
+	// The route pattern is only known once chi has matched the request
+	if s.routing != nil && s.routing.RoutePattern() != "" {
+		attrs = append(attrs, slog.String("route", s.routing.RoutePattern()))
+	}
+	if s.username != "" {
+		attrs = append(attrs, slog.String("username", s.username))
+	}
+	return attrs
+}
+
+// SetUsername records the username a request acts on, for its log records
+func SetUsername(ctx context.Context, username string) {
+	if state := stateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.username = username
+		state.mu.Unlock()
+	}
+}
+
+// AddPhrases registers phrase text from a request so it is scrubbed from any
+// record logged with the request's context, wherever it appears
+func AddPhrases(ctx context.Context, phrases ...string) {
+	if state := stateFrom(ctx); state != nil {
+		state.mu.Lock()
+		state.phrases = append(state.phrases, phrases...)
+		state.compiled = phraseScrubber(state.phrases)
+		state.mu.Unlock()
+	}
+}
+
+// Middleware sets up request-scoped log fields and writes an access log
+// record when the request completes. It must run after middleware.RequestID.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		state := &requestState{
+			id:      middleware.GetReqID(r.Context()),
+			method:  r.Method,
+			routing: chi.RouteContext(r.Context()),
+		}
+		ctx := context.WithValue(r.Context(), stateKey{}, state)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(ctx, level, "Request completed",
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int("bytes", ww.BytesWritten()),
+			slog.Duration("duration", time.Since(start)),
+		)
+	})
+}
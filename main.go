@@ -1,83 +1,110 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 
+	"semantic-auth/api"
 	"semantic-auth/cache"
+	"semantic-auth/config"
 	"semantic-auth/db"
 	"semantic-auth/handlers"
+	"semantic-auth/logging"
+	"semantic-auth/metrics"
 	"semantic-auth/moderation"
+	"semantic-auth/openai"
+	"semantic-auth/sessions"
+	"semantic-auth/tracing"
+	"semantic-auth/webhooks"
 )
 
 func main() {
 	// Load environment variables from .env file only in local development
 	// In production, environment variables should be set in the environment
+	var envErr error
 	if os.Getenv("GO_ENV") != "production" {
-		err := godotenv.Load()
-		if err != nil {
-			log.Println("Warning: .env file not found, using environment variables")
-		}
+		envErr = godotenv.Load()
 	}
 
+	// Load and validate the configuration, refusing to start on any problem
+	cfg, err := config.Load(config.Requirements{Embedding: true})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Log as JSON at the configured level, with phrases redacted
+	logging.Initialize(cfg.Log)
+	if envErr != nil {
+		slog.Warn(".env file not found, using environment variables")
+	}
+	cfg.LogSummary()
+
+	// Export traces if an OTLP endpoint is configured
+	shutdownTracing := tracing.Initialize(context.Background(), cfg.Tracing)
+
 	// Connect to MongoDB
-	db.Connect()
+	db.Connect(cfg.Mongo)
 
 	// Initialize moderation service and check health
-	moderation.Initialize()
+	moderation.Initialize(cfg.Moderation)
+
+	// Read embedding settings before the cache validates against them
+	openai.Initialize(cfg.OpenAI)
 
 	// Initialize semantic cache client
-	cache.Initialize()
+	cache.Initialize(cfg.Cache)
 
-	// Setup router
-	r := chi.NewRouter()
+	// Issue sessions on successful logins
+	sessions.Initialize(cfg.Session)
 
-	// Get CORS allowed origins from environment variable
-	// Format: comma-separated list of domains (e.g., "https://example.com,https://app.example.com")
-	// Default to "*" (all origins) if not specified
-	corsOrigins := os.Getenv("CORS_ALLOWED_ORIGINS")
-	allowedOrigins := []string{"*"} // Default to all origins
+	// Apply scoring settings used by the handlers
+	handlers.Initialize(cfg)
 
-	if corsOrigins != "" {
-		// Split the comma-separated list into a slice
-		allowedOrigins = strings.Split(corsOrigins, ",")
-	}
+	// Deliver webhooks for authentication events
+	webhooks.Start()
+
+	// Setup router
+	r := chi.NewRouter()
 
+	// CORS allowed origins, "*" (all origins) unless configured
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   allowedOrigins,
+		AllowedOrigins:   cfg.Server.CORSOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
 		MaxAge:           300, // 5 minutes
 	}))
-	r.Use(middleware.Logger)
+	r.Use(middleware.RequestID)
+	r.Use(logging.Middleware)
+	r.Use(tracing.Middleware)
+	r.Use(metrics.Middleware)
 
 	// Health check
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("OK"))
 	})
+	r.Get("/health/live", handlers.LiveHandler)
+	r.Get("/health/ready", handlers.ReadyHandler)
 
-	// Register route
-	r.Post("/register", handlers.RegisterHandler)
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler())
 
-	// Login route
-	r.Post("/login", handlers.LoginHandler)
+	// Versioned API and its OpenAPI document
+	r.Route(api.Prefix, handlers.Mount)
 
-	// Report route
-	r.Get("/report", handlers.ReportHandler)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Start server and shut it down gracefully on SIGINT or SIGTERM
+	if err := serve(newServer(cfg.Server, r), cfg.Server.ShutdownTimeout, shutdownTracing); err != nil {
+		slog.Error("Server stopped", "error", err)
+		os.Exit(1)
 	}
-	// Start server
-	log.Println("Listening on :" + port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
 }
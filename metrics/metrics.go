@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "semauth"
+
+// Embedding sources, cache tiers and dependencies used as label values
+const (
+	SourceLocal         = "in_process"
+	SourceSemanticCache = "semantic_cache"
+	SourceRedis         = "redis"
+	SourceMongo         = "mongo"
+	SourceModeration    = "moderation"
+	SourceOpenAI        = "openai"
+)
+
+var (
+	// LoginAttempts counts logins by outcome: success, failure, locked, unknown_user, rejected, error
+	LoginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_attempts_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+
+	// LoginSimilarity is the distribution of scored login similarities
+	LoginSimilarity = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "login_similarity",
+		Help:      "Similarity scores of login attempts.",
+		Buckets:   prometheus.LinearBuckets(0, 0.05, 21),
+	})
+
+	// Registrations counts successful registrations
+	Registrations = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Users registered.",
+	})
+
+	// EmbeddingDuration is the time to produce an embedding, by the source that answered
+	EmbeddingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "embedding_duration_seconds",
+		Help:      "Time to obtain an embedding, by source.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source"})
+
+	// CacheLookups counts embedding cache lookups by tier and result (hit or miss)
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Embedding cache lookups by tier and result.",
+	}, []string{"tier", "result"})
+
+	// ModerationDuration is the latency of moderation service calls
+	ModerationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "moderation_duration_seconds",
+		Help:      "Moderation service request latency.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// ModerationRejections counts rejected phrases by primary category
+	ModerationRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "moderation_rejections_total",
+		Help:      "Phrases rejected by moderation, by category.",
+	}, []string{"category"})
+
+	// DependencyErrors counts failed calls to external dependencies
+	DependencyErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "dependency_errors_total",
+		Help:      "Errors calling external dependencies.",
+	}, []string{"dependency"})
+
+	// EventsDropped counts events a slow event bus subscriber had no room for
+	EventsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_dropped_total",
+		Help:      "Events dropped by the event bus, by subscriber.",
+	}, []string{"subscriber"})
+
+	localCacheEntries = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "local_cache_entries",
+		Help:      "Embeddings held in the in-process cache.",
+	}, func() float64 {
+		if entries := localCacheSize.Load(); entries != nil {
+			return float64((*entries)())
+		}
+		return 0
+	})
+
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+// localCacheSize reports the in-process cache's entry count, once set
+var localCacheSize atomic.Pointer[func() int]
+
+// WatchLocalCache makes the local_cache_entries gauge report entries when scraped
+func WatchLocalCache(entries func() int) {
+	localCacheSize.Store(&entries)
+}
+
+// CacheResult records a cache lookup as a hit or miss
+func CacheResult(tier string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	CacheLookups.WithLabelValues(tier, result).Inc()
+}
+
+// Since returns the seconds elapsed since start, for Observe calls
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records request counts and latency by chi route pattern, so
+// path parameters don't create a series per ID
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(Since(start))
+	})
+}
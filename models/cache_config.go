@@ -1,19 +1,46 @@
 package models
 
+import "time"
+
 // CacheConfig represents the configuration for the semantic cache
 type CacheConfig struct {
-	Enabled           bool    `json:"enabled"`
-	URL               string  `json:"url"`
+	Enabled             bool    `json:"enabled"`
+	Backend             string  `json:"backend"` // "http" (semantic cache service) or "redis"
+	URL                 string  `json:"url"`
 	SimilarityThreshold float64 `json:"similarity_threshold"`
-	AllowFallback     bool    `json:"allow_fallback"`
+	AllowFallback       bool    `json:"allow_fallback"`
+
+	// ExactMatchForAuth only accepts hits for the exact input text on
+	// authentication paths, never a near match for a different phrase
+	ExactMatchForAuth bool `json:"exact_match_for_auth"`
+
+	// Model and Dimensions that cached vectors must match to be used
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+
+	// Redis backend settings
+	KeyPrefix string        `json:"key_prefix"`
+	TTL       time.Duration `json:"ttl"`
+
+	// In-process LRU tier checked before the semantic cache
+	LocalSize int           `json:"local_size"`
+	LocalTTL  time.Duration `json:"local_ttl"`
 }
 
 // DefaultCacheConfig returns the default cache configuration
 func DefaultCacheConfig() CacheConfig {
 	return CacheConfig{
-		Enabled:           false,
-		URL:               "http://localhost:8081",
+		Enabled:             false,
+		Backend:             "http",
+		URL:                 "http://localhost:8081",
 		SimilarityThreshold: 0.88,
-		AllowFallback:     true,
+		AllowFallback:       true,
+		ExactMatchForAuth:   true,
+		Model:               DefaultEmbeddingModel,
+		Dimensions:          EmbeddingDimensions,
+		KeyPrefix:           "semauth:emb",
+		TTL:                 30 * 24 * time.Hour,
+		LocalSize:           1000,
+		LocalTTL:            10 * time.Minute,
 	}
 }
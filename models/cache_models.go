@@ -2,16 +2,26 @@ package models
 
 // CacheRequest represents the incoming request to the cache API
 type CacheRequest struct {
-	Text          string `json:"text" binding:"required"`
-	SourceSystem  string `json:"source_system" binding:"required"`
-	AllowFallback bool   `json:"allow_fallback,omitempty"`
-	Response      string `json:"response,omitempty"` // Optional response to store with this input
+	Text                string  `json:"text" binding:"required"`
+	SourceSystem        string  `json:"source_system" binding:"required"`
+	AllowFallback       bool    `json:"allow_fallback,omitempty"`
+	SimilarityThreshold float64 `json:"similarity_threshold,omitempty"`
+	Response            string  `json:"response,omitempty"` // Optional response to store with this input
 }
 
 // CacheResponse represents the response from the cache API
 type CacheResponse struct {
-	Cached    bool    `json:"cached"`
-	Response  string  `json:"response,omitempty"`
-	SectorKey string  `json:"sector_key,omitempty"`
-	Similarity float64 `json:"similarity,omitempty"`
+	Cached     bool     `json:"cached"`
+	Response   string   `json:"response,omitempty"`
+	SectorKey  string   `json:"sector_key,omitempty"`
+	Similarity *float64 `json:"similarity,omitempty"` // nil when the service did not report one
+}
+
+// CachedEmbedding is the payload stored as the cache response for an input.
+// Older entries hold a bare JSON array of floats instead, which are no
+// longer served since they don't say which model produced them.
+type CachedEmbedding struct {
+	Model      string    `json:"model"`
+	Dimensions int       `json:"dimensions"`
+	Vector     []float32 `json:"vector"`
 }
@@ -1,7 +1,21 @@
 package models
 
+const (
+	// DefaultEmbeddingModel is the OpenAI model used for all embeddings
+	DefaultEmbeddingModel = "text-embedding-3-small"
+
+	// DefaultEmbeddingDimensions is the vector length DefaultEmbeddingModel returns
+	DefaultEmbeddingDimensions = 1536
+)
+
+// EmbeddingDimensions is the vector length requested for new embeddings.
+// It is set from EMBEDDING_DIMENSIONS by openai.Initialize.
+var EmbeddingDimensions = DefaultEmbeddingDimensions
+
 type Embedding struct {
-	Hash   string    `bson:"hash"`
-	Input  string    `bson:"input"`
-	Vector []float64 `bson:"vector"`
+	Hash       string `bson:"hash"`
+	Input      string `bson:"input"`
+	Vector     Vector `bson:"vector"`
+	Model      string `bson:"model,omitempty"`
+	Dimensions int    `bson:"dimensions,omitempty"`
 }
@@ -1,10 +1,28 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// LabelGenuine marks an attempt made by the account owner
+	LabelGenuine = "genuine"
+
+	// LabelImpostor marks an attempt made by someone else
+	LabelImpostor = "impostor"
+)
 
 type LoginAttempt struct {
-	Username   string    `bson:"username"`
-	Input      string    `bson:"input"`
-	Similarity float64   `bson:"similarity"`
-	Timestamp  time.Time `bson:"timestamp"`
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Username   string             `bson:"username"`
+	Input      string             `bson:"input"`
+	Similarity float64            `bson:"similarity"`
+	Threshold  float64            `bson:"threshold,omitempty"` // threshold in effect for this attempt
+	Passed     bool               `bson:"passed"`
+	Scorer     string             `bson:"scorer,omitempty"`
+	Aggregator string             `bson:"aggregator,omitempty"`
+	Label      string             `bson:"label,omitempty"` // operator-assigned LabelGenuine or LabelImpostor
+	Timestamp  time.Time          `bson:"timestamp"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type ModerationEvent struct {
+	Username       string             `bson:"username"`
+	Category       string             `bson:"category"`
+	Categories     []string           `bson:"categories,omitempty"`
+	Scores         map[string]float64 `bson:"scores,omitempty"`
+	ServiceVersion string             `bson:"service_version,omitempty"`
+	Content        string             `bson:"content,omitempty"`
+	Timestamp      time.Time          `bson:"timestamp"`
+}
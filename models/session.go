@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Session is a login session. Only a hash of the bearer token is stored,
+// so a database read does not yield usable tokens.
+type Session struct {
+	TokenHash       string    `bson:"_id"`
+	Username        string    `bson:"username"`
+	AuthenticatedAt time.Time `bson:"authenticated_at"` // when the login succeeded
+	ExpiresAt       time.Time `bson:"expires_at"`
+	Similarity      float64   `bson:"similarity"` // score of the login that created it
+}
@@ -1,8 +1,38 @@
 package models
 
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+)
+
 type User struct {
-	Username string    `bson:"username"`
-	Hash     string    `bson:"hash"`
-	Vector   []float64 `bson:"vector"`
-	Raw      string    `bson:"raw,omitempty"`
+	Username   string   `bson:"username"`
+	Hash       string   `bson:"hash"`
+	Vector     Vector   `bson:"vector"`
+	Vectors    []Vector `bson:"vectors,omitempty"` // additional enrolled phrases
+	Model      string   `bson:"model,omitempty"`
+	Dimensions int      `bson:"dimensions,omitempty"` // length of Vector when enrolled
+	Scorer     string   `bson:"scorer,omitempty"`     // overrides the deployment scorer
+	Aggregator string   `bson:"aggregator,omitempty"` // overrides the deployment aggregator
+	Raw        string   `bson:"raw,omitempty"`
+
+	// Lockout state, see LOGIN_LOCKOUT_ATTEMPTS
+	FailedAttempts int       `bson:"failed_attempts,omitempty"`
+	LockedUntil    time.Time `bson:"locked_until,omitempty"`
+}
+
+// AllVectors returns the primary vector followed by any additional ones
+func (u User) AllVectors() [][]float32 {
+	vectors := [][]float32{u.Vector}
+	for _, v := range u.Vectors {
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+// HashPhrase returns the hash stored for a user's primary phrase
+func HashPhrase(phrase string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.ToLower(phrase))))
 }
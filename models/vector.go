@@ -0,0 +1,174 @@
+package models
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/x/bsonx/bsoncore"
+)
+
+// VectorEncoding selects how vectors are written to MongoDB
+type VectorEncoding byte
+
+const (
+	// VectorFloat32 stores each component as a little-endian float32
+	VectorFloat32 VectorEncoding = 1
+
+	// VectorInt8 stores one float32 scale followed by one int8 per component,
+	// a quarter of the float32 size. Cosine scores of 1536-dimension embeddings
+	// shift by up to about 0.003; see TestVectorEncodingAccuracy and the README.
+	VectorInt8 VectorEncoding = 2
+)
+
+// StoredVectorEncoding is the encoding used when writing vectors
+var StoredVectorEncoding = VectorFloat32
+
+// ParseVectorEncoding parses "float32" or "int8"
+func ParseVectorEncoding(s string) (VectorEncoding, error) {
+	switch s {
+	case "float32":
+		return VectorFloat32, nil
+	case "int8":
+		return VectorInt8, nil
+	default:
+		return 0, fmt.Errorf("unknown vector encoding %q", s)
+	}
+}
+
+func (e VectorEncoding) String() string {
+	switch e {
+	case VectorFloat32:
+		return "float32"
+	case VectorInt8:
+		return "int8"
+	default:
+		return fmt.Sprintf("VectorEncoding(%d)", byte(e))
+	}
+}
+
+// Vector is an embedding held as float32. In BSON it is a compact binary
+// value: one encoding byte followed by the float32 or int8 payload. Legacy
+// documents holding an array of doubles are still readable.
+type Vector []float32
+
+// MarshalBSONValue encodes the vector with StoredVectorEncoding
+func (v Vector) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	if v == nil {
+		return bsontype.Null, nil, nil
+	}
+
+	var payload []byte
+	switch StoredVectorEncoding {
+	case VectorInt8:
+		payload = v.encodeInt8()
+	default:
+		payload = v.encodeFloat32()
+	}
+
+	return bsontype.Binary, bsoncore.AppendBinary(nil, bsontype.BinaryGeneric, payload), nil
+}
+
+// UnmarshalBSONValue decodes a binary vector in either encoding, or a legacy array of numbers
+func (v *Vector) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	value := bsoncore.Value{Type: t, Data: data}
+
+	switch t {
+	case bsontype.Null:
+		*v = nil
+		return nil
+
+	case bsontype.Array:
+		values, err := value.Array().Values()
+		if err != nil {
+			return fmt.Errorf("invalid vector array: %w", err)
+		}
+		out := make(Vector, len(values))
+		for i, elem := range values {
+			switch elem.Type {
+			case bsontype.Double:
+				out[i] = float32(elem.Double())
+			case bsontype.Int32, bsontype.Int64:
+				out[i] = float32(elem.AsInt64())
+			default:
+				return fmt.Errorf("invalid vector component at index %d", i)
+			}
+		}
+		*v = out
+		return nil
+
+	case bsontype.Binary:
+		_, payload, ok := value.BinaryOK()
+		if !ok || len(payload) == 0 {
+			return fmt.Errorf("invalid vector binary")
+		}
+		switch VectorEncoding(payload[0]) {
+		case VectorFloat32:
+			return v.decodeFloat32(payload[1:])
+		case VectorInt8:
+			return v.decodeInt8(payload[1:])
+		default:
+			return fmt.Errorf("unknown vector encoding %d", payload[0])
+		}
+
+	default:
+		return fmt.Errorf("cannot decode vector from BSON %s", t)
+	}
+}
+
+func (v Vector) encodeFloat32() []byte {
+	buf := make([]byte, 1+4*len(v))
+	buf[0] = byte(VectorFloat32)
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[1+4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func (v *Vector) decodeFloat32(data []byte) error {
+	if len(data)%4 != 0 {
+		return fmt.Errorf("invalid float32 vector length %d", len(data))
+	}
+	out := make(Vector, len(data)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	*v = out
+	return nil
+}
+
+// encodeInt8 applies symmetric scalar quantization with a per-vector scale
+func (v Vector) encodeInt8() []byte {
+	var maxAbs float32
+	for _, f := range v {
+		if a := float32(math.Abs(float64(f))); a > maxAbs {
+			maxAbs = a
+		}
+	}
+	scale := maxAbs / 127
+	if scale == 0 {
+		scale = 1
+	}
+
+	buf := make([]byte, 1+4+len(v))
+	buf[0] = byte(VectorInt8)
+	binary.LittleEndian.PutUint32(buf[1:], math.Float32bits(scale))
+	for i, f := range v {
+		buf[5+i] = byte(int8(math.Round(float64(f / scale))))
+	}
+	return buf
+}
+
+func (v *Vector) decodeInt8(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("invalid int8 vector length %d", len(data))
+	}
+	scale := math.Float32frombits(binary.LittleEndian.Uint32(data))
+	out := make(Vector, len(data)-4)
+	for i, b := range data[4:] {
+		out[i] = float32(int8(b)) * scale
+	}
+	*v = out
+	return nil
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"semantic-auth/internal/testvec"
+	"semantic-auth/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// roundTrip stores v with encoding and reads it back
+func roundTrip(t *testing.T, v Vector, encoding VectorEncoding) Vector {
+	t.Helper()
+	saved := StoredVectorEncoding
+	StoredVectorEncoding = encoding
+	defer func() { StoredVectorEncoding = saved }()
+
+	data, err := bson.Marshal(bson.M{"v": v})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		V Vector `bson:"v"`
+	}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.V
+}
+
+func TestVectorBSONRoundTrip(t *testing.T) {
+	v := Vector{0.5, -0.25, 0.125, 0, -1}
+
+	got := roundTrip(t, v, VectorFloat32)
+	for i := range v {
+		if got[i] != v[i] {
+			t.Fatalf("float32 round trip changed component %d: %v -> %v", i, v[i], got[i])
+		}
+	}
+
+	got = roundTrip(t, v, VectorInt8)
+	for i := range v {
+		if math.Abs(float64(got[i]-v[i])) > 1.0/127/2+1e-7 {
+			t.Errorf("int8 round trip moved component %d too far: %v -> %v", i, v[i], got[i])
+		}
+	}
+
+	if got := roundTrip(t, nil, VectorFloat32); got != nil {
+		t.Errorf("nil vector decoded as %v", got)
+	}
+}
+
+func TestVectorDecodesLegacyArrays(t *testing.T) {
+	data, err := bson.Marshal(bson.M{"v": []float64{0.5, -0.25, 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		V Vector `bson:"v"`
+	}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Vector{0.5, -0.25, 3}); len(doc.V) != 3 || doc.V[0] != want[0] || doc.V[1] != want[1] || doc.V[2] != want[2] {
+		t.Errorf("legacy array decoded as %v, want %v", doc.V, want)
+	}
+}
+
+// TestVectorSliceReEncodes checks that a list of legacy vectors, as in a
+// user's additional phrases, decodes and is written back in binary form
+func TestVectorSliceReEncodes(t *testing.T) {
+	data, err := bson.Marshal(bson.M{"vectors": [][]float64{{0.5, -0.25}, {1, 0}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Vectors []Vector `bson:"vectors"`
+	}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Vectors) != 2 || doc.Vectors[0][1] != -0.25 || doc.Vectors[1][0] != 1 {
+		t.Fatalf("legacy vectors decoded as %v", doc.Vectors)
+	}
+
+	saved := StoredVectorEncoding
+	StoredVectorEncoding = VectorInt8
+	defer func() { StoredVectorEncoding = saved }()
+
+	data, err = bson.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := bson.Raw(data).Lookup("vectors").Array().Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		_, payload, ok := v.BinaryOK()
+		if !ok || VectorEncoding(payload[0]) != VectorInt8 {
+			t.Errorf("vectors[%d] stored as %s, want int8 binary", i, v.Type)
+		}
+	}
+}
+
+// TestVectorEncodingAccuracy measures how far each stored encoding moves the
+// cosine similarity of near-duplicate 1536-dimension pairs whose true scores
+// span 0.80 to 0.99, around the default 0.88 threshold. The README quotes
+// its -v output.
+func TestVectorEncodingAccuracy(t *testing.T) {
+	cases := []struct {
+		name    string
+		outlier float64
+		bounds  map[VectorEncoding]float64
+	}{
+		{"isotropic", 1, map[VectorEncoding]float64{VectorFloat32: 1e-5, VectorInt8: 1e-3}},
+		{"outlier dimensions", 8, map[VectorEncoding]float64{VectorFloat32: 1e-5, VectorInt8: 5e-3}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			measureEncodingAccuracy(t, c.outlier, c.bounds)
+		})
+	}
+
+	legacy, err := bson.Marshal(bson.M{"v": make([]float64, 1536)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("legacy float64 array: about %d bytes per vector", len(legacy))
+}
+
+func measureEncodingAccuracy(t *testing.T, outlier float64, bounds map[VectorEncoding]float64) {
+	const (
+		pairs     = 2000
+		dims      = 1536
+		threshold = 0.88
+	)
+
+	rng := rand.New(rand.NewSource(1))
+	type pair struct {
+		a, b Vector
+		want float64
+	}
+	samples := make([]pair, pairs)
+	for i := range samples {
+		a := testvec.EmbeddingLike(rng, dims, outlier)
+		b := testvec.NearDuplicate(rng, a, 0.80+0.19*rng.Float64())
+		want, err := utils.CosineSimilarity(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		samples[i] = pair{Vector(testvec.Float32(a)), Vector(testvec.Float32(b)), want}
+	}
+
+	for _, encoding := range []VectorEncoding{VectorFloat32, VectorInt8} {
+		var maxErr, sumErr float64
+		var flips, nearThreshold int
+		for _, p := range samples {
+			got, err := utils.CosineSimilarity32(roundTrip(t, p.a, encoding), roundTrip(t, p.b, encoding))
+			if err != nil {
+				t.Fatal(err)
+			}
+			diff := math.Abs(got - p.want)
+			maxErr = math.Max(maxErr, diff)
+			sumErr += diff
+
+			if math.Abs(p.want-threshold) <= bounds[encoding] {
+				nearThreshold++
+			}
+			if (got >= threshold) != (p.want >= threshold) {
+				flips++
+			}
+		}
+
+		size, err := encodedSize(encoding, dims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%s: %d bytes per vector, max error %.1e, mean error %.1e, %d of %d pairs flipped at %.2f (%d scored within %.0e of it)",
+			encoding, size, maxErr, sumErr/pairs, flips, pairs, threshold, nearThreshold, bounds[encoding])
+
+		if maxErr >= bounds[encoding] {
+			t.Errorf("%s: max cosine error %.2e, want < %.0e", encoding, maxErr, bounds[encoding])
+		}
+	}
+}
+
+// encodedSize is the size of the BSON value of a dims-long vector in encoding
+func encodedSize(encoding VectorEncoding, dims int) (int, error) {
+	saved := StoredVectorEncoding
+	StoredVectorEncoding = encoding
+	defer func() { StoredVectorEncoding = saved }()
+
+	_, data, err := make(Vector, dims).MarshalBSONValue()
+	return len(data), err
+}
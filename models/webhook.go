@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type WebhookSubscription struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	URL       string             `bson:"url" json:"url"`
+	Secret    string             `bson:"secret" json:"secret,omitempty"` // HMAC key, only returned on creation
+	Events    []string           `bson:"events" json:"events"`           // event types, empty for all
+	Active    bool               `bson:"active" json:"active"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// WebhookDeadLetter is a delivery that failed every retry
+type WebhookDeadLetter struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SubscriptionID primitive.ObjectID `bson:"subscription_id" json:"subscription_id"`
+	URL            string             `bson:"url" json:"url"`
+	EventType      string             `bson:"event_type" json:"event_type"`
+	Payload        string             `bson:"payload" json:"payload"`
+	Attempts       int                `bson:"attempts" json:"attempts"`
+	LastError      string             `bson:"last_error" json:"last_error"`
+	FailedAt       time.Time          `bson:"failed_at" json:"failed_at"`
+}
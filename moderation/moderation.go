@@ -1,21 +1,83 @@
 package moderation
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"sort"
 	"strings"
+	"time"
+
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/metrics"
+	"semantic-auth/models"
+	"semantic-auth/tracing"
 
 	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-var client = resty.New()
+var client = tracing.InstrumentResty(resty.New())
+
+var (
+	// baseURL is the moderation service URL, without a trailing slash
+	baseURL string
+
+	// storeContent controls whether rejected content is kept on moderation events
+	storeContent = false
+)
 
 // ModerationResponse represents the response from the moderation service
 type ModerationResponse struct {
-	Allowed bool   `json:"allowed"`
-	Message string `json:"message,omitempty"`
-	Version string `json:"version,omitempty"`
+	Allowed        bool               `json:"allowed"`
+	Message        string             `json:"message,omitempty"`
+	Version        string             `json:"version,omitempty"`
+	Categories     map[string]bool    `json:"categories,omitempty"`
+	CategoryScores map[string]float64 `json:"category_scores,omitempty"`
+}
+
+// FlaggedCategories returns the names of the categories the service flagged, sorted
+func (m *ModerationResponse) FlaggedCategories() []string {
+	var flagged []string
+	for name, hit := range m.Categories {
+		if hit {
+			flagged = append(flagged, name)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// PrimaryCategory returns the flagged category with the highest score.
+// Falls back to "uncategorized" when the service gave no category labels.
+func (m *ModerationResponse) PrimaryCategory() string {
+	primary := ""
+	best := -1.0
+	for _, name := range m.FlaggedCategories() {
+		if score := m.CategoryScores[name]; score > best {
+			primary = name
+			best = score
+		}
+	}
+	if primary == "" {
+		return "uncategorized"
+	}
+	return primary
+}
+
+// RejectedError is returned when content is not allowed by the moderation service
+type RejectedError struct {
+	Response *ModerationResponse
+}
+
+func (e *RejectedError) Error() string {
+	message := "Content not allowed by moderation service"
+	if e.Response != nil && e.Response.Message != "" {
+		message = e.Response.Message
+	}
+	return fmt.Sprintf("moderation error: %s", message)
 }
 
 // HealthResponse represents the response from the moderation service health endpoint
@@ -25,49 +87,58 @@ type HealthResponse struct {
 }
 
 // Initialize checks the health of the moderation service and logs the result
-func Initialize() {
-	moderationBaseURL := os.Getenv("MODERATION_SERVICE_URL")
-	if moderationBaseURL == "" {
-		log.Println("WARNING: MODERATION_SERVICE_URL environment variable not set")
+func Initialize(cfg config.Moderation) {
+	baseURL = strings.TrimSuffix(cfg.URL, "/")
+	storeContent = cfg.StoreContent
+
+	result, err := HealthCheck(context.Background())
+	if err != nil {
+		slog.Warn("Moderation service health check failed", "error", err)
 		return
 	}
+	slog.Info("Moderation service health check", "status", result.Status, "version", result.Version)
+}
 
-	// Ensure the URL doesn't end with a slash
-	moderationBaseURL = strings.TrimSuffix(moderationBaseURL, "/")
+// HealthCheck queries the moderation service health endpoint
+func HealthCheck(ctx context.Context) (*HealthResponse, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("moderation service URL is not configured")
+	}
 
 	// Check health endpoint
-	healthURL := fmt.Sprintf("%s/api/health", moderationBaseURL)
+	healthURL := fmt.Sprintf("%s/api/health", baseURL)
 	resp, err := client.R().
+		SetContext(ctx).
 		SetResult(&HealthResponse{}).
 		Get(healthURL)
 
 	if err != nil {
-		log.Printf("WARNING: Moderation service health check failed: %v", err)
-		return
+		return nil, fmt.Errorf("moderation service request failed: %w", err)
 	}
 
 	if resp.StatusCode() >= 400 {
-		log.Printf("WARNING: Moderation service health check returned status %d", resp.StatusCode())
-		return
+		return nil, fmt.Errorf("moderation service returned error status: %d", resp.StatusCode())
 	}
 
-	result := resp.Result().(*HealthResponse)
-	log.Printf("Moderation service health check: Status=%s, Version=%s", result.Status, result.Version)
+	return resp.Result().(*HealthResponse), nil
 }
 
 // CheckContent sends content to the moderation service and returns whether it's allowed
-func CheckContent(content string) (*ModerationResponse, error) {
-	// Get moderation service URL from environment variable
-	moderationBaseURL := os.Getenv("MODERATION_SERVICE_URL")
-	if moderationBaseURL == "" {
-		return nil, fmt.Errorf("missing MODERATION_SERVICE_URL environment variable")
+func CheckContent(ctx context.Context, content string) (result *ModerationResponse, err error) {
+	ctx, span := tracing.Start(ctx, "moderation.CheckContent")
+	defer func() {
+		if result != nil {
+			span.SetAttributes(attribute.Bool("moderation.allowed", result.Allowed))
+		}
+		tracing.End(span, err)
+	}()
+
+	if baseURL == "" {
+		return nil, fmt.Errorf("moderation service URL is not configured")
 	}
 
-	// Ensure the URL doesn't end with a slash
-	moderationBaseURL = strings.TrimSuffix(moderationBaseURL, "/")
-
 	// Construct the full URL with the /api/moderate endpoint
-	moderationURL := fmt.Sprintf("%s/api/moderate", moderationBaseURL)
+	moderationURL := fmt.Sprintf("%s/api/moderate", baseURL)
 
 	// Prepare request body
 	body := map[string]interface{}{
@@ -76,20 +147,62 @@ func CheckContent(content string) (*ModerationResponse, error) {
 	}
 
 	// Send request to moderation service
+	start := time.Now()
 	resp, err := client.R().
+		SetContext(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(body).
 		SetResult(&ModerationResponse{}).
 		Post(moderationURL)
+	metrics.ModerationDuration.Observe(metrics.Since(start))
 
 	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceModeration).Inc()
 		return nil, fmt.Errorf("moderation service request failed: %w", err)
 	}
 
 	if resp.StatusCode() >= 400 {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceModeration).Inc()
 		return nil, fmt.Errorf("moderation service returned error status: %d", resp.StatusCode())
 	}
 
-	result := resp.Result().(*ModerationResponse)
+	result = resp.Result().(*ModerationResponse)
+	if !result.Allowed {
+		metrics.ModerationRejections.WithLabelValues(result.PrimaryCategory()).Inc()
+	}
 	return result, nil
 }
+
+// RecordRejection stores a moderation_events entry for a rejected input.
+// The raw content is only kept when MODERATION_STORE_CONTENT is enabled.
+// This is a best-effort operation - errors are logged but not returned
+func RecordRejection(ctx context.Context, username, content string, resp *ModerationResponse) {
+	if resp == nil {
+		resp = &ModerationResponse{}
+	}
+
+	event := models.ModerationEvent{
+		Username:       username,
+		Category:       resp.PrimaryCategory(),
+		Categories:     resp.FlaggedCategories(),
+		Scores:         resp.CategoryScores,
+		ServiceVersion: resp.Version,
+		Timestamp:      time.Now(),
+	}
+	if storeContent {
+		event.Content = content
+	}
+
+	_, err := db.Client.Database("semantic_auth").Collection("moderation_events").
+		InsertOne(ctx, event)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to record moderation event", "error", err)
+	}
+
+	events.Default.Publish(events.Event{
+		Type:      events.ModerationRejected,
+		Username:  username,
+		Timestamp: event.Timestamp,
+		Data:      event,
+	})
+}
@@ -0,0 +1,228 @@
+package openai
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"semantic-auth/cache"
+	"semantic-auth/db"
+	"semantic-auth/metrics"
+	"semantic-auth/models"
+	"semantic-auth/tracing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OpenAI accepts at most 2048 inputs and roughly 300k tokens per embeddings
+// request. The character budget keeps a batch well under the token cap at
+// about four characters per token.
+const (
+	maxBatchInputs = 2048
+	maxBatchChars  = 1000000
+)
+
+// BatchError reports which input of a batch failed, e.g. a moderation rejection
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("input %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// EmbedBatch embeds several inputs, returning vectors aligned with inputs.
+// Each input goes through the same moderation and cache tiers as Embed, and
+// only the misses are sent to OpenAI, in as few requests as the limits allow.
+func EmbedBatch(ctx context.Context, inputs []string) (vectors [][]float32, err error) {
+	ctx, span := tracing.Start(ctx, "openai.EmbedBatch", attribute.Int("embedding.inputs", len(inputs)))
+	defer func() { tracing.End(span, err) }()
+
+	vectors = make([][]float32, len(inputs))
+
+	// Normalize and group duplicate inputs so each phrase is looked up once
+	var unique []string
+	positions := make(map[string][]int)
+	for i, input := range inputs {
+		clean := strings.TrimSpace(strings.ToLower(input))
+		if _, seen := positions[clean]; !seen {
+			unique = append(unique, clean)
+		}
+		positions[clean] = append(positions[clean], i)
+	}
+
+	found := make(map[string][]float32, len(unique))
+
+	// In-process cache
+	var pending []string
+	for _, clean := range unique {
+		if cache.DefaultLocal != nil {
+			if vector, ok := cache.DefaultLocal.Get(clean); ok {
+				found[clean] = vector
+				continue
+			}
+		}
+		pending = append(pending, clean)
+	}
+
+	// Moderation, for everything not already known to be allowed
+	for _, clean := range pending {
+		if err := checkModeration(ctx, clean); err != nil {
+			return nil, &BatchError{Index: positions[clean][0], Err: err}
+		}
+	}
+
+	// External cache
+	if len(pending) > 0 && cache.DefaultClient != nil && cache.DefaultClient.IsEnabled() {
+		cached := cache.DefaultClient.GetEmbeddings(ctx, pending, cache.LookupAuth)
+		pending = collectHits(pending, cached, found)
+	}
+
+	// Local cache (MongoDB), only reached when something is still missing
+	var collection *mongo.Collection
+	if len(pending) > 0 {
+		collection = db.Client.Database("semantic_auth").Collection("embeddings")
+		hashes := make([]string, len(pending))
+		byHash := make(map[string]string, len(pending))
+		for i, clean := range pending {
+			hashes[i] = fmt.Sprintf("%x", sha256.Sum256([]byte(clean)))
+			byHash[hashes[i]] = clean
+		}
+
+		findCtx, span := tracing.Start(ctx, "mongo.embeddings.Find", attribute.Int("embedding.inputs", len(hashes)))
+		docs, err := findEmbeddings(findCtx, collection, hashes)
+		tracing.End(span, err)
+		if err != nil {
+			metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+			return nil, err
+		}
+
+		for _, doc := range docs {
+			if clean, ok := byHash[doc.Hash]; ok {
+				found[clean] = doc.Vector
+			}
+		}
+
+		var remaining []string
+		for _, clean := range pending {
+			_, ok := found[clean]
+			metrics.CacheResult(metrics.SourceMongo, ok)
+			if !ok {
+				remaining = append(remaining, clean)
+			}
+		}
+		pending = remaining
+	}
+
+	// Hit OpenAI for the misses
+	var fresh []string
+	var freshVectors [][]float32
+	for _, batch := range splitBatches(pending) {
+		start := time.Now()
+		batchVectors, err := requestEmbeddings(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		metrics.EmbeddingDuration.WithLabelValues(metrics.SourceOpenAI).Observe(metrics.Since(start))
+		for i, clean := range batch {
+			found[clean] = batchVectors[i]
+		}
+		fresh = append(fresh, batch...)
+		freshVectors = append(freshVectors, batchVectors...)
+	}
+
+	// Write new embeddings back to the caches in bulk
+	if len(fresh) > 0 {
+		docs := make([]interface{}, len(fresh))
+		for i, clean := range fresh {
+			docs[i] = models.Embedding{
+				Hash:       fmt.Sprintf("%x", sha256.Sum256([]byte(clean))),
+				Input:      clean,
+				Vector:     freshVectors[i],
+				Model:      models.DefaultEmbeddingModel,
+				Dimensions: len(freshVectors[i]),
+			}
+		}
+		_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+		if err != nil {
+			metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+			slog.WarnContext(ctx, "Failed to save embeddings to local cache", "error", err)
+			// Continue despite the error
+		}
+
+		if cache.DefaultClient != nil && cache.DefaultClient.IsEnabled() {
+			inBackground(func(ctx context.Context) {
+				cache.DefaultClient.StoreEmbeddings(ctx, fresh, freshVectors)
+			})
+		}
+	}
+
+	for clean, indices := range positions {
+		vector := found[clean]
+		if cache.DefaultLocal != nil {
+			cache.DefaultLocal.Set(clean, vector)
+		}
+		for _, i := range indices {
+			vectors[i] = vector
+		}
+	}
+
+	return vectors, nil
+}
+
+// findEmbeddings loads the stored embeddings for hashes at the target dimensions
+func findEmbeddings(ctx context.Context, collection *mongo.Collection, hashes []string) ([]models.Embedding, error) {
+	cursor, err := collection.Find(ctx, dimensionFilter(bson.M{"hash": bson.M{"$in": hashes}}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []models.Embedding
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// collectHits moves non-nil cache results into found and returns the inputs still missing
+func collectHits(inputs []string, cached [][]float32, found map[string][]float32) []string {
+	var missing []string
+	for i, clean := range inputs {
+		if cached[i] != nil {
+			found[clean] = cached[i]
+		} else {
+			missing = append(missing, clean)
+		}
+	}
+	return missing
+}
+
+// splitBatches groups inputs into requests within maxBatchInputs and maxBatchChars
+func splitBatches(inputs []string) [][]string {
+	var batches [][]string
+	var current []string
+	chars := 0
+	for _, input := range inputs {
+		if len(current) > 0 && (len(current) == maxBatchInputs || chars+len(input) > maxBatchChars) {
+			batches = append(batches, current)
+			current = nil
+			chars = 0
+		}
+		current = append(current, input)
+		chars += len(input)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
@@ -0,0 +1,151 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"semantic-auth/moderation"
+)
+
+// inputs returns n distinct inputs of size characters each
+func inputs(n, size int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("%0*d", size, i)
+	}
+	return out
+}
+
+func TestSplitBatches(t *testing.T) {
+	huge := strings.Repeat("x", maxBatchChars+1)
+	half := strings.Repeat("y", maxBatchChars/2)
+
+	cases := []struct {
+		name   string
+		inputs []string
+		sizes  []int // inputs per batch
+	}{
+		{"none", nil, nil},
+		{"one", []string{"a"}, []int{1}},
+		{"exactly the input limit", inputs(maxBatchInputs, 4), []int{maxBatchInputs}},
+		{"one over the input limit", inputs(maxBatchInputs+1, 4), []int{maxBatchInputs, 1}},
+		{"twice the input limit", inputs(2*maxBatchInputs, 4), []int{maxBatchInputs, maxBatchInputs}},
+		{"exactly the character limit", []string{half, half}, []int{2}},
+		{"one character over", []string{half, half, "z"}, []int{2, 1}},
+		// An input over the budget on its own still goes out, alone
+		{"single input over the character limit", []string{huge}, []int{1}},
+		{"oversize input between others", []string{"a", huge, "b"}, []int{1, 1, 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			batches := splitBatches(tc.inputs)
+
+			var sizes []int
+			var joined []string
+			for _, batch := range batches {
+				sizes = append(sizes, len(batch))
+				joined = append(joined, batch...)
+			}
+			if !slices.Equal(sizes, tc.sizes) {
+				t.Errorf("batch sizes %v, want %v", sizes, tc.sizes)
+			}
+			if !slices.Equal(joined, tc.inputs) {
+				t.Error("batches do not hold the inputs in order")
+			}
+		})
+	}
+}
+
+func TestCollectHits(t *testing.T) {
+	v1, v2 := []float32{1}, []float32{2}
+	cases := []struct {
+		name    string
+		inputs  []string
+		cached  [][]float32
+		missing []string
+		found   map[string][]float32
+	}{
+		{"all hits", []string{"a", "b"}, [][]float32{v1, v2}, nil, map[string][]float32{"a": v1, "b": v2}},
+		{"all misses", []string{"a", "b"}, [][]float32{nil, nil}, []string{"a", "b"}, map[string][]float32{}},
+		{"mixed keeps order", []string{"a", "b", "c", "d"}, [][]float32{nil, v1, nil, v2}, []string{"a", "c"}, map[string][]float32{"b": v1, "d": v2}},
+		{"empty vector is a hit", []string{"a"}, [][]float32{{}}, nil, map[string][]float32{"a": {}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			found := map[string][]float32{}
+			missing := collectHits(tc.inputs, tc.cached, found)
+			if !slices.Equal(missing, tc.missing) {
+				t.Errorf("missing %q, want %q", missing, tc.missing)
+			}
+			if len(found) != len(tc.found) {
+				t.Errorf("found %v, want %v", found, tc.found)
+			}
+			for input, want := range tc.found {
+				if got, ok := found[input]; !ok || !slices.Equal(got, want) {
+					t.Errorf("found[%q] = %v, want %v", input, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedBatchDuplicates(t *testing.T) {
+	vectors := map[string][]float32{"a": {1, 0}, "b": {0, 1}, "c": {0.6, 0.8}}
+	cases := []struct {
+		name   string
+		inputs []string
+		unique []string // looked up in the cache, in order
+	}{
+		{"no duplicates", []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"exact duplicates", []string{"a", "a", "a"}, []string{"a"}},
+		{"duplicates after normalization", []string{"A", "b", " a ", "B", "c", "a\n"}, []string{"a", "b", "c"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := moderate(t)
+			backend := &fakeBackend{vectors: vectors}
+			useBackend(t, backend)
+
+			got, err := EmbedBatch(context.Background(), tc.inputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tc.inputs) {
+				t.Fatalf("%d vectors for %d inputs", len(got), len(tc.inputs))
+			}
+			// Every index gets its own phrase's vector
+			for i, input := range tc.inputs {
+				want := vectors[strings.TrimSpace(strings.ToLower(input))]
+				if !slices.Equal(got[i], want) {
+					t.Errorf("vector %d for %q = %v, want %v", i, input, got[i], want)
+				}
+			}
+			if len(backend.batches) != 1 || !slices.Equal(backend.batches[0], tc.unique) {
+				t.Errorf("cache lookups %q, want one of %q", backend.batches, tc.unique)
+			}
+			if got := int(checks.Load()); got != len(tc.unique) {
+				t.Errorf("%d moderation checks, want one per phrase (%d)", got, len(tc.unique))
+			}
+		})
+	}
+}
+
+func TestEmbedBatchRejectionIndex(t *testing.T) {
+	moderate(t, "c")
+	useBackend(t, &fakeBackend{})
+
+	// The rejection names the first position of the rejected phrase
+	_, err := EmbedBatch(context.Background(), []string{"a", "b", "a", "C", "c"})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 3 {
+		t.Fatalf("err = %v, want a BatchError for input 3", err)
+	}
+	var rejected *moderation.RejectedError
+	if !errors.As(err, &rejected) {
+		t.Errorf("err = %v, want it to wrap the moderation rejection", err)
+	}
+}
@@ -5,51 +5,114 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"strings"
+	"sync"
+	"time"
 
 	"semantic-auth/cache"
+	"semantic-auth/config"
 	"semantic-auth/db"
+	"semantic-auth/metrics"
 	"semantic-auth/models"
 	"semantic-auth/moderation"
+	"semantic-auth/tracing"
+	"semantic-auth/utils"
 
 	"github.com/go-resty/resty/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
 )
 
-var client = resty.New()
+var client = tracing.InstrumentResty(resty.New())
 
-func Embed(input string) ([]float64, error) {
+// apiKey authenticates requests to OpenAI
+var apiKey string
+
+// Initialize sets the API key and target embedding dimensions.
+// It must run before cache.Initialize so cached vectors are validated against it.
+func Initialize(cfg config.OpenAI) {
+	apiKey = cfg.APIKey
+	models.EmbeddingDimensions = cfg.Dimensions
+	slog.Info("Embedding settings", "model", models.DefaultEmbeddingModel, "dimensions", models.EmbeddingDimensions)
+}
+
+// CheckConfig reports whether embeddings can be requested from OpenAI.
+// It does not call the API, so probes never spend quota.
+func CheckConfig() error {
+	if apiKey == "" {
+		return fmt.Errorf("OpenAI API key is not configured")
+	}
+	return nil
+}
+
+// inflight de-duplicates concurrent embedding requests for the same input
+var inflight singleflight.Group
+
+// Embed returns the embedding of input, checking moderation and each cache
+// tier before calling OpenAI
+func Embed(ctx context.Context, input string) (vector []float32, err error) {
+	ctx, span := tracing.Start(ctx, "openai.Embed")
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
 	clean := strings.TrimSpace(strings.ToLower(input))
 	hash := fmt.Sprintf("%x", sha256.Sum256([]byte(clean)))
 
-	// Check content with moderation service
-	modResp, err := moderation.CheckContent(clean)
+	// Check the in-process cache first; anything in it already passed moderation
+	if cache.DefaultLocal != nil {
+		if vector, ok := cache.DefaultLocal.Get(clean); ok {
+			span.SetAttributes(attribute.String("embedding.source", metrics.SourceLocal))
+			metrics.EmbeddingDuration.WithLabelValues(metrics.SourceLocal).Observe(metrics.Since(start))
+			return vector, nil
+		}
+	}
+
+	// Concurrent callers with the same input share one upstream lookup, which
+	// must not be cancelled just because the first caller gave up
+	result, err, shared := inflight.Do(hash, func() (interface{}, error) {
+		return embedUncached(context.WithoutCancel(ctx), clean, hash)
+	})
+	span.SetAttributes(attribute.Bool("embedding.shared", shared))
 	if err != nil {
-		return nil, fmt.Errorf("moderation check failed: %w", err)
+		return nil, err
 	}
 
-	// If content is not allowed, return error
-	if !modResp.Allowed {
-		message := "Content not allowed by moderation service"
-		if modResp.Message != "" {
-			message = modResp.Message
-		}
-		return nil, fmt.Errorf("moderation error: %s", message)
+	embedded := result.(sourcedVector)
+	span.SetAttributes(attribute.String("embedding.source", embedded.source))
+	metrics.EmbeddingDuration.WithLabelValues(embedded.source).Observe(metrics.Since(start))
+	if cache.DefaultLocal != nil {
+		cache.DefaultLocal.Set(clean, embedded.vector)
+	}
+
+	return embedded.vector, nil
+}
+
+// sourcedVector is an embedding along with the tier that produced it
+type sourcedVector struct {
+	vector []float32
+	source string
+}
+
+// embedUncached runs moderation and the remote cache, Mongo and OpenAI lookups for a normalized input
+func embedUncached(ctx context.Context, clean, hash string) (sourcedVector, error) {
+	// Check content with moderation service
+	if err := checkModeration(ctx, clean); err != nil {
+		return sourcedVector{}, err
 	}
 
 	// Try to get embedding from external semantic cache if enabled
 	if cache.DefaultClient != nil && cache.DefaultClient.IsEnabled() {
-		vector, err := cache.DefaultClient.GetEmbedding(context.Background(), clean)
+		vector, err := cache.DefaultClient.GetEmbedding(ctx, clean, cache.LookupAuth)
 		if err == nil {
 			// Successfully retrieved from external cache
-			log.Printf("Retrieved embedding from semantic cache for input: %s", clean)
-			return vector, nil
+			slog.DebugContext(ctx, "Retrieved embedding from semantic cache", "backend", cache.DefaultClient.Source())
+			return sourcedVector{vector, cache.DefaultClient.Source()}, nil
 		} else {
 			// Log the error but continue with fallback
-			log.Printf("Semantic cache retrieval failed: %v, falling back to local cache/OpenAI", err)
+			slog.DebugContext(ctx, "Semantic cache retrieval failed, falling back to local cache/OpenAI", "error", err)
 		}
 	}
 
@@ -57,67 +120,172 @@ func Embed(input string) ([]float64, error) {
 
 	// Check local cache (MongoDB)
 	var cached models.Embedding
-	err = collection.FindOne(context.TODO(), bson.M{"hash": hash}).Decode(&cached)
+	findCtx, span := tracing.Start(ctx, "mongo.embeddings.FindOne")
+	err := collection.FindOne(findCtx, dimensionFilter(bson.M{"hash": hash})).Decode(&cached)
+	span.SetAttributes(attribute.Bool("cache.hit", err == nil))
+	if err == mongo.ErrNoDocuments {
+		tracing.End(span, nil)
+	} else {
+		tracing.End(span, err)
+	}
 	if err == nil {
-		return cached.Vector, nil
+		metrics.CacheResult(metrics.SourceMongo, true)
+		return sourcedVector{cached.Vector, metrics.SourceMongo}, nil
 	} else if err != mongo.ErrNoDocuments {
-		return nil, err
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+		return sourcedVector{}, err
 	}
+	metrics.CacheResult(metrics.SourceMongo, false)
 
 	// Hit OpenAI
-	openaiKey := os.Getenv("OPENAI_KEY")
-	if openaiKey == "" {
-		return nil, fmt.Errorf("missing OPENAI_KEY")
+	vectors, err := requestEmbeddings(ctx, []string{clean})
+	if err != nil {
+		return sourcedVector{}, err
+	}
+	vector := vectors[0]
+
+	// Cache it locally
+	embedding := models.Embedding{
+		Hash:       hash,
+		Input:      clean,
+		Vector:     vector,
+		Model:      models.DefaultEmbeddingModel,
+		Dimensions: len(vector),
+	}
+	_, err = collection.InsertOne(ctx, embedding)
+	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceMongo).Inc()
+		slog.WarnContext(ctx, "Failed to save embedding to local cache", "error", err)
+		// Continue despite the error
+	}
+
+	// Store in external semantic cache if enabled
+	if cache.DefaultClient != nil && cache.DefaultClient.IsEnabled() {
+		inBackground(func(ctx context.Context) {
+			cache.DefaultClient.StoreEmbedding(ctx, clean, vector)
+		})
+	}
+
+	return sourcedVector{vector, metrics.SourceOpenAI}, nil
+}
+
+// background tracks cache writes that outlive the request that started them
+var background sync.WaitGroup
+
+// inBackground runs a best-effort write without holding up the caller
+func inBackground(write func(ctx context.Context)) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		write(context.Background())
+	}()
+}
+
+// Shutdown waits for background cache writes to finish, or until ctx is done
+func Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background cache writes still running: %w", ctx.Err())
+	}
+}
+
+// checkModeration returns a *moderation.RejectedError if the moderation service rejects the input
+func checkModeration(ctx context.Context, clean string) error {
+	modResp, err := moderation.CheckContent(ctx, clean)
+	if err != nil {
+		return fmt.Errorf("moderation check failed: %w", err)
+	}
+
+	// If content is not allowed, return error
+	if !modResp.Allowed {
+		return &moderation.RejectedError{Response: modResp}
+	}
+
+	return nil
+}
+
+// requestEmbeddings sends inputs to OpenAI in a single request and returns
+// their vectors in input order. Callers must respect the batch limits below.
+func requestEmbeddings(ctx context.Context, inputs []string) (vectors [][]float32, err error) {
+	ctx, span := tracing.Start(ctx, "openai.requestEmbeddings", attribute.Int("embedding.inputs", len(inputs)))
+	defer func() { tracing.End(span, err) }()
+
+	if apiKey == "" {
+		return nil, fmt.Errorf("OpenAI API key is not configured")
 	}
 
 	body := map[string]interface{}{
-		"input": clean,
-		"model": "text-embedding-3-small",
+		"input": inputs,
+		"model": models.DefaultEmbeddingModel,
+	}
+	if models.EmbeddingDimensions < models.DefaultEmbeddingDimensions && supportsDimensions(models.DefaultEmbeddingModel) {
+		body["dimensions"] = models.EmbeddingDimensions
 	}
 
 	resp, err := client.R().
-		SetHeader("Authorization", "Bearer "+openaiKey).
+		SetContext(ctx).
+		SetHeader("Authorization", "Bearer "+apiKey).
 		SetHeader("Content-Type", "application/json").
 		SetBody(body).
 		Post("https://api.openai.com/v1/embeddings")
 
 	if err != nil {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceOpenAI).Inc()
 		return nil, err
 	}
 
+	if resp.StatusCode() >= 400 {
+		metrics.DependencyErrors.WithLabelValues(metrics.SourceOpenAI).Inc()
+		return nil, fmt.Errorf("OpenAI returned error status: %d", resp.StatusCode())
+	}
+
 	var result struct {
 		Data []struct {
-			Embedding []float64 `json:"embedding"`
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
 		} `json:"data"`
 	}
 	if err := json.Unmarshal(resp.Body(), &result); err != nil {
 		return nil, err
 	}
 
-	if len(result.Data) == 0 {
-		return nil, fmt.Errorf("no embedding returned")
+	if len(result.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(result.Data))
 	}
 
-	vector := result.Data[0].Embedding
-
-	// Cache it locally
-	embedding := models.Embedding{
-		Hash:   hash,
-		Input:  clean,
-		Vector: vector,
-	}
-	_, err = collection.InsertOne(context.TODO(), embedding)
-	if err != nil {
-		log.Printf("Warning: Failed to save embedding to local cache: %v", err)
-		// Continue despite the error
+	vectors = make([][]float32, len(inputs))
+	for _, d := range result.Data {
+		if d.Index < 0 || d.Index >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		// Shorten anything the provider returned at full size
+		vectors[d.Index] = utils.Truncate(d.Embedding, models.EmbeddingDimensions)
 	}
 
-	// Store in external semantic cache if enabled
-	if cache.DefaultClient != nil && cache.DefaultClient.IsEnabled() {
-		go func() {
-			cache.DefaultClient.StoreEmbedding(context.Background(), clean, vector)
-		}()
-	}
+	return vectors, nil
+}
+
+// supportsDimensions reports whether the model accepts the dimensions parameter
+func supportsDimensions(model string) bool {
+	return strings.HasPrefix(model, "text-embedding-3")
+}
 
-	return vector, nil
+// dimensionFilter restricts an embeddings query to vectors of the target
+// dimensions. Documents written before dimensions were recorded hold
+// full-size vectors and only match when no reduction is configured.
+func dimensionFilter(filter bson.M) bson.M {
+	if models.EmbeddingDimensions == models.DefaultEmbeddingDimensions {
+		filter["dimensions"] = bson.M{"$in": bson.A{models.EmbeddingDimensions, nil}}
+	} else {
+		filter["dimensions"] = models.EmbeddingDimensions
+	}
+	return filter
 }
@@ -0,0 +1,148 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"semantic-auth/cache"
+	"semantic-auth/config"
+	"semantic-auth/metrics"
+	"semantic-auth/moderation"
+)
+
+// fakeBackend is a cache backend holding vectors. When release is set,
+// single lookups wait for it to close, so concurrent Embed calls pile up
+// behind the first.
+type fakeBackend struct {
+	vectors map[string][]float32
+	release chan struct{}
+	lookups atomic.Int32
+	batches [][]string
+}
+
+func (b *fakeBackend) GetEmbedding(ctx context.Context, input string, mode cache.LookupMode) ([]float32, error) {
+	b.lookups.Add(1)
+	if b.release != nil {
+		<-b.release
+	}
+	if vector, ok := b.vectors[input]; ok {
+		return vector, nil
+	}
+	return nil, errors.New("miss")
+}
+
+func (b *fakeBackend) StoreEmbedding(ctx context.Context, input string, vector []float32) {}
+
+func (b *fakeBackend) GetEmbeddings(ctx context.Context, inputs []string, mode cache.LookupMode) [][]float32 {
+	b.batches = append(b.batches, inputs)
+	result := make([][]float32, len(inputs))
+	for i, input := range inputs {
+		result[i] = b.vectors[input]
+	}
+	return result
+}
+
+func (b *fakeBackend) StoreEmbeddings(ctx context.Context, inputs []string, vectors [][]float32) {}
+
+func (b *fakeBackend) IsEnabled() bool                      { return true }
+func (b *fakeBackend) Source() string                       { return metrics.SourceRedis }
+func (b *fakeBackend) HealthCheck(ctx context.Context) bool { return true }
+
+// useBackend makes backend the shared cache, without an in-process cache
+func useBackend(t *testing.T, backend cache.Backend) {
+	t.Helper()
+	cache.DefaultClient, cache.DefaultLocal = backend, nil
+	t.Cleanup(func() { cache.DefaultClient, cache.DefaultLocal = nil, nil })
+}
+
+// moderate points moderation at a server rejecting only the given inputs,
+// and returns its count of checks
+func moderate(t *testing.T, rejected ...string) *atomic.Int32 {
+	t.Helper()
+	var checks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/moderate" {
+			checks.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		var req struct {
+			Content string `json:"content"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(moderation.ModerationResponse{Allowed: !slices.Contains(rejected, req.Content)})
+	}))
+	t.Cleanup(server.Close)
+	moderation.Initialize(config.Moderation{URL: server.URL})
+	return &checks
+}
+
+var sesame = map[string][]float32{"open sesame": {0.6, 0.8}}
+
+func TestEmbedSharesConcurrentLookups(t *testing.T) {
+	checks := moderate(t)
+	backend := &fakeBackend{vectors: sesame, release: make(chan struct{})}
+	useBackend(t, backend)
+
+	// The same phrase, written differently
+	inputs := []string{"open sesame", "Open Sesame", "  OPEN SESAME ", "open sesame\n"}
+	const callers = 4
+	results := make([][]float32, len(inputs)*callers)
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = Embed(context.Background(), inputs[i%len(inputs)])
+		}()
+	}
+
+	// Let every caller reach the shared lookup before it answers
+	deadline := time.Now().Add(5 * time.Second)
+	for backend.lookups.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil || len(results[i]) != 2 || results[i][0] != 0.6 {
+			t.Errorf("caller %d got %v, %v", i, results[i], errs[i])
+		}
+	}
+	if got := backend.lookups.Load(); got != 1 {
+		t.Errorf("%d cache lookups for one phrase, want 1", got)
+	}
+	if got := checks.Load(); got != 1 {
+		t.Errorf("%d moderation checks for one phrase, want 1", got)
+	}
+}
+
+func TestEmbedFillsLocalCache(t *testing.T) {
+	checks := moderate(t)
+	backend := &fakeBackend{vectors: sesame}
+	useBackend(t, backend)
+	cache.DefaultLocal = cache.NewLocalCache(10, time.Minute, []byte("key"))
+
+	for _, input := range []string{"open sesame", "Open Sesame"} {
+		if _, err := Embed(context.Background(), input); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The second call is answered in process, without moderation or the shared cache
+	if backend.lookups.Load() != 1 || checks.Load() != 1 {
+		t.Errorf("%d cache lookups and %d moderation checks, want 1 each", backend.lookups.Load(), checks.Load())
+	}
+	if stats := cache.DefaultLocal.Stats(); stats.Hits != 1 || stats.Misses != 1 || stats.Entries != 1 {
+		t.Errorf("local cache stats %+v", stats)
+	}
+}
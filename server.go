@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/handlers"
+	"semantic-auth/openai"
+	"semantic-auth/webhooks"
+)
+
+// newServer creates the HTTP server with the configured port and timeouts.
+// Event streams and exports lift the write timeout for themselves.
+func newServer(cfg config.Server, handler http.Handler) *http.Server {
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+	srv.RegisterOnShutdown(handlers.CloseStreams)
+	return srv
+}
+
+// serve runs srv until SIGINT or SIGTERM, then drains in-flight requests and
+// background work, giving each up to timeout, before closing dependencies.
+// A second signal exits immediately.
+func serve(srv *http.Server, timeout time.Duration, shutdownTracing func(context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "addr", srv.Addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	stop()
+
+	slog.Info("Shutting down", "timeout", timeout)
+
+	err := shutdownStage(timeout, srv.Shutdown)
+	if err != nil {
+		slog.Warn("Requests still running at shutdown deadline", "error", err)
+	}
+	closeDependencies(timeout, shutdownTracing)
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("Shutdown complete")
+	return nil
+}
+
+// Deadlines for the shutdown stages that only talk to our own dependencies;
+// waiting on requests, webhook subscribers and cache writes gets the full
+// SHUTDOWN_TIMEOUT each
+const (
+	deadLetterTimeout = 10 * time.Second
+	flushTimeout      = 10 * time.Second
+	disconnectTimeout = 10 * time.Second
+)
+
+// shutdownStage runs one step of shutdown under its own deadline, so a step
+// that runs out of time doesn't leave the later ones an expired context
+func shutdownStage(timeout time.Duration, step func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return step(ctx)
+}
+
+// closeDependencies waits for background work and then closes connections.
+// Mongo goes last since webhook dead letters are written to it.
+func closeDependencies(timeout time.Duration, shutdownTracing func(context.Context) error) {
+	if err := shutdownStage(timeout, webhooks.Shutdown); err != nil {
+		slog.Warn("Webhook deliveries did not finish", "error", err)
+	}
+	// Deliveries abandoned above are dead-lettered as the workers exit
+	if err := shutdownStage(deadLetterTimeout, webhooks.Wait); err != nil {
+		slog.Warn("Webhook dead letters were not all written", "error", err)
+	}
+	if err := shutdownStage(timeout, openai.Shutdown); err != nil {
+		slog.Warn("Cache writes did not finish", "error", err)
+	}
+	if err := shutdownStage(flushTimeout, shutdownTracing); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
+	if err := shutdownStage(disconnectTimeout, db.Disconnect); err != nil {
+		slog.Warn("Failed to disconnect from MongoDB", "error", err)
+	}
+}
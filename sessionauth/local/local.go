@@ -0,0 +1,29 @@
+// Package local verifies sessions against the semanticAuth database, for
+// routes served by the semanticAuth server itself. It is kept apart from
+// sessionauth so that services which introspect remotely don't link the
+// server, its storage and its metrics.
+package local
+
+import (
+	"context"
+	"errors"
+
+	"semantic-auth/api"
+	"semantic-auth/sessionauth"
+	"semantic-auth/sessions"
+)
+
+// Verifier looks sessions up in the database
+func Verifier() sessionauth.Verifier {
+	return sessionauth.VerifierFunc(func(ctx context.Context, token string) (*api.SessionResponse, error) {
+		session, err := sessions.Lookup(ctx, token)
+		if errors.Is(err, sessions.ErrInvalid) {
+			return nil, sessionauth.ErrInvalidSession
+		}
+		if err != nil {
+			return nil, err
+		}
+		resp := sessions.Response(session)
+		return &resp, nil
+	})
+}
@@ -0,0 +1,145 @@
+// Package sessionauth is chi middleware that protects routes with
+// semanticAuth sessions. It checks the "Authorization: Bearer" token of each
+// request and stores the session in the request context.
+//
+// Services check sessions by introspecting them on a semanticAuth server;
+// the server itself uses the database-backed verifier in sessionauth/local.
+// This package imports only the client and api packages, not the server:
+//
+//	auth := sessionauth.Remote(client.New("https://auth.example.com"))
+//	r.Group(func(r chi.Router) {
+//		r.Use(sessionauth.Require(auth))
+//		r.Get("/profile", profileHandler)
+//		r.With(sessionauth.RequireRecent(10 * time.Minute)).Post("/email", changeEmailHandler)
+//	})
+//
+// Rejected requests get the usual error envelope with code invalid_session,
+// or reauthentication_required when a session is too old for a step-up route.
+package sessionauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/client"
+)
+
+// ErrInvalidSession is returned by a Verifier for a token that is unknown,
+// revoked or expired
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+// Verifier resolves a session token to its session. Tokens that are not a
+// live session give ErrInvalidSession; any other error means the session
+// could not be checked.
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*api.SessionResponse, error)
+}
+
+// VerifierFunc adapts a function to a Verifier
+type VerifierFunc func(ctx context.Context, token string) (*api.SessionResponse, error)
+
+func (f VerifierFunc) Verify(ctx context.Context, token string) (*api.SessionResponse, error) {
+	return f(ctx, token)
+}
+
+// Remote verifies sessions by introspecting them on a semanticAuth server
+func Remote(c *client.Client) Verifier {
+	return VerifierFunc(func(ctx context.Context, token string) (*api.SessionResponse, error) {
+		session, err := c.Introspect(ctx, token)
+		if errors.Is(err, client.ErrInvalidSession) {
+			return nil, ErrInvalidSession
+		}
+		if err != nil {
+			return nil, fmt.Errorf("introspect session: %w", err)
+		}
+		return session, nil
+	})
+}
+
+type contextKey struct{}
+
+// Session returns the session stored by Require, if any
+func Session(ctx context.Context) (*api.SessionResponse, bool) {
+	session, ok := ctx.Value(contextKey{}).(*api.SessionResponse)
+	return session, ok
+}
+
+// Username returns the user of the session stored by Require, or ""
+func Username(ctx context.Context) string {
+	if session, ok := Session(ctx); ok {
+		return session.Username
+	}
+	return ""
+}
+
+// Require rejects requests without a live session token and stores the
+// session in the context of the rest
+func Require(v Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := api.BearerToken(r)
+			if token == "" {
+				unauthorized(w, api.CodeInvalidSession, "Missing session token")
+				return
+			}
+
+			session, err := v.Verify(r.Context(), token)
+			if errors.Is(err, ErrInvalidSession) {
+				unauthorized(w, api.CodeInvalidSession, "Invalid or expired session")
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Failed to verify session", "error", err)
+				respondWithError(w, http.StatusServiceUnavailable, api.CodeUnavailable, "Failed to verify session")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), contextKey{}, session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRecent is a step-up check for sensitive routes: it rejects sessions
+// whose login is older than maxAge, so the user has to log in again. It must
+// run after Require.
+func RequireRecent(maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, ok := Session(r.Context())
+			if !ok {
+				unauthorized(w, api.CodeInvalidSession, "Missing session token")
+				return
+			}
+			if time.Since(session.AuthenticatedAt) > maxAge {
+				unauthorized(w, api.CodeReauthRequired, "Please log in again to continue")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unauthorized sends a 401 with a challenge naming the failure
+func unauthorized(w http.ResponseWriter, code, message string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, code))
+	respondWithError(w, http.StatusUnauthorized, code, message)
+}
+
+// respondWithError writes the API's error envelope
+func respondWithError(w http.ResponseWriter, statusCode int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(api.StandardResponse{
+		Status:  "error",
+		Success: false,
+		Message: message,
+		Code:    code,
+	})
+}
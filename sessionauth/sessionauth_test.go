@@ -0,0 +1,155 @@
+package sessionauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/client"
+	"semantic-auth/sessionauth"
+)
+
+// sessionsByToken is a Verifier over fixed sessions
+type sessionsByToken map[string]*api.SessionResponse
+
+func (s sessionsByToken) Verify(ctx context.Context, token string) (*api.SessionResponse, error) {
+	if token == "broken" {
+		return nil, errors.New("connection refused")
+	}
+	session, ok := s[token]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return nil, sessionauth.ErrInvalidSession
+	}
+	return session, nil
+}
+
+// protected serves "ok:<username>" behind Require, and behind RequireRecent
+// as well when maxAge is set
+func protected(v sessionauth.Verifier, maxAge time.Duration) http.Handler {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok:" + sessionauth.Username(r.Context())))
+	})
+	if maxAge > 0 {
+		h = sessionauth.RequireRecent(maxAge)(h)
+	}
+	return sessionauth.Require(v)(h)
+}
+
+func TestRequire(t *testing.T) {
+	now := time.Now()
+	verifier := sessionsByToken{
+		"live":    {Username: "steve", AuthenticatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour)},
+		"expired": {Username: "steve", AuthenticatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+		"fresh":   {Username: "alice", AuthenticatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)},
+	}
+
+	cases := []struct {
+		name   string
+		header string
+		maxAge time.Duration
+		status int
+		code   string
+	}{
+		{"live session", "Bearer live", 0, http.StatusOK, ""},
+		{"missing token", "", 0, http.StatusUnauthorized, api.CodeInvalidSession},
+		{"not a bearer token", "Basic c3RldmU6eA==", 0, http.StatusUnauthorized, api.CodeInvalidSession},
+		{"unknown token", "Bearer nope", 0, http.StatusUnauthorized, api.CodeInvalidSession},
+		{"expired session", "Bearer expired", 0, http.StatusUnauthorized, api.CodeInvalidSession},
+		{"verifier down", "Bearer broken", 0, http.StatusServiceUnavailable, api.CodeUnavailable},
+		{"recent enough", "Bearer fresh", 10 * time.Minute, http.StatusOK, ""},
+		{"stale for step-up", "Bearer live", 10 * time.Minute, http.StatusUnauthorized, api.CodeReauthRequired},
+		{"expired for step-up", "Bearer expired", 10 * time.Minute, http.StatusUnauthorized, api.CodeInvalidSession},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			rec := httptest.NewRecorder()
+			protected(verifier, tc.maxAge).ServeHTTP(rec, req)
+
+			if rec.Code != tc.status {
+				t.Fatalf("status %d, want %d (%s)", rec.Code, tc.status, rec.Body)
+			}
+			if tc.status == http.StatusOK {
+				if got := rec.Body.String(); got != "ok:"+verifier[tc.header[len("Bearer "):]].Username {
+					t.Errorf("body %q, handler did not see the session", got)
+				}
+				return
+			}
+
+			var resp api.StandardResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("body is not the error envelope: %v", err)
+			}
+			if resp.Success || resp.Code != tc.code {
+				t.Errorf("response %+v, want code %s", resp, tc.code)
+			}
+			if tc.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestRequireRecentWithoutRequire(t *testing.T) {
+	h := sessionauth.RequireRecent(time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler ran without a session")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401", rec.Code)
+	}
+}
+
+// TestRemote checks the remote verifier against a server answering introspection
+func TestRemote(t *testing.T) {
+	authenticatedAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req api.IntrospectRequest
+		json.NewDecoder(r.Body).Decode(&req)
+
+		w.Header().Set("Content-Type", "application/json")
+		switch req.Token {
+		case "live":
+			json.NewEncoder(w).Encode(api.StandardResponse{Status: "success", Success: true, Data: api.SessionResponse{
+				Username: "steve", AuthenticatedAt: authenticatedAt, ExpiresAt: authenticatedAt.Add(time.Hour),
+			}})
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(api.StandardResponse{Status: "error", Code: api.CodeInternal, Message: "boom"})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.StandardResponse{Status: "error", Code: api.CodeInvalidSession, Message: "Invalid or expired session"})
+		}
+	}))
+	defer server.Close()
+	verifier := sessionauth.Remote(client.New(server.URL, client.WithRetries(0, 0)))
+
+	session, err := verifier.Verify(context.Background(), "live")
+	if err != nil || session.Username != "steve" || !session.AuthenticatedAt.Equal(authenticatedAt) {
+		t.Errorf("Verify(live) = %+v, %v", session, err)
+	}
+	if _, err := verifier.Verify(context.Background(), "revoked"); !errors.Is(err, sessionauth.ErrInvalidSession) {
+		t.Errorf("Verify(revoked) error = %v, want ErrInvalidSession", err)
+	}
+	if _, err := verifier.Verify(context.Background(), "broken"); err == nil || errors.Is(err, sessionauth.ErrInvalidSession) {
+		t.Errorf("Verify(broken) error = %v, want a failure to check", err)
+	}
+
+	// A stale session found remotely is refused by the step-up check
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/email", nil)
+	req.Header.Set("Authorization", "Bearer live")
+	protected(verifier, 30*time.Second).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("step-up with a minute-old session: status %d, want 401", rec.Code)
+	}
+}
@@ -0,0 +1,125 @@
+// Package sessions issues and checks the bearer tokens returned by a
+// successful login. Sessions live in MongoDB keyed by a hash of the token and
+// are removed by a TTL index once they expire.
+package sessions
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"semantic-auth/api"
+	"semantic-auth/config"
+	"semantic-auth/db"
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrInvalid is returned for a token that is unknown, revoked or expired
+var ErrInvalid = errors.New("invalid or expired session")
+
+// ttl is how long a session stays valid after login
+var ttl = 24 * time.Hour
+
+// Initialize sets the session lifetime and makes sure expired sessions are
+// cleaned up by MongoDB
+func Initialize(cfg config.Session) {
+	ttl = cfg.TTL
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := collection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		slog.Warn("Failed to create session expiry index", "error", err)
+	}
+}
+
+func collection() *mongo.Collection {
+	return db.Client.Database("semantic_auth").Collection("sessions")
+}
+
+// hashToken is the stored form of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Issue creates a session for a user who just logged in and returns its token
+func Issue(ctx context.Context, username string, similarity float64) (string, models.Session, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", models.Session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	now := time.Now().UTC()
+	session := models.Session{
+		TokenHash:       hashToken(token),
+		Username:        username,
+		AuthenticatedAt: now,
+		ExpiresAt:       now.Add(ttl),
+		Similarity:      similarity,
+	}
+	if _, err := collection().InsertOne(ctx, session); err != nil {
+		return "", models.Session{}, err
+	}
+	return token, session, nil
+}
+
+// Lookup returns the live session for token, or ErrInvalid. Expiry is
+// checked here since the TTL index only sweeps periodically.
+func Lookup(ctx context.Context, token string) (models.Session, error) {
+	var session models.Session
+	if token == "" {
+		return session, ErrInvalid
+	}
+
+	err := collection().FindOne(ctx, bson.M{"_id": hashToken(token)}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return session, ErrInvalid
+	}
+	if err != nil {
+		return session, err
+	}
+	if !session.ExpiresAt.After(time.Now()) {
+		return session, ErrInvalid
+	}
+	return session, nil
+}
+
+// Response converts a stored session for output
+func Response(session models.Session) api.SessionResponse {
+	return api.SessionResponse{
+		Username:        session.Username,
+		AuthenticatedAt: session.AuthenticatedAt,
+		ExpiresAt:       session.ExpiresAt,
+		Similarity:      session.Similarity,
+	}
+}
+
+// Revoke ends the session for token. Revoking an unknown token is not an error.
+func Revoke(ctx context.Context, token string) error {
+	_, err := collection().DeleteOne(ctx, bson.M{"_id": hashToken(token)})
+	return err
+}
+
+// RevokeUser ends every session of a user, e.g. after their phrase is reset
+func RevokeUser(ctx context.Context, username string) (int64, error) {
+	result, err := collection().DeleteMany(ctx, bson.M{"username": username})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
@@ -0,0 +1,170 @@
+package tracing
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"semantic-auth/config"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "semantic-auth"
+
+// Tracer creates the spans for handlers and upstream calls. It is a no-op
+// until Initialize installs an exporter.
+var Tracer = otel.Tracer(instrumentationName)
+
+// Initialize installs an OTLP/HTTP trace exporter when an endpoint is
+// configured. The exporter reads the remaining OTEL_EXPORTER_OTLP_* variables
+// (headers, timeout, compression) itself. Without an endpoint, tracing stays
+// a no-op. The returned function flushes and stops the exporter.
+func Initialize(ctx context.Context, cfg config.Tracing) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	endpoint := cfg.TracesEndpoint
+	if endpoint == "" && cfg.Endpoint != "" {
+		endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		slog.Info("Tracing is disabled")
+		return func(context.Context) error { return nil }
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		slog.Warn("Failed to create OTLP exporter, tracing disabled", "error", err)
+		return func(context.Context) error { return nil }
+	}
+
+	serviceName := cfg.ServiceName
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL, semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		res = resource.Default()
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	slog.Info("Tracing enabled", "service", serviceName)
+	return provider.Shutdown
+}
+
+// Start begins a span. Attributes must never carry phrase text.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span for each request, continuing any trace
+// from the incoming headers. The span is named after the chi route pattern
+// once routing has happened.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := Tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method)),
+		)
+		defer span.End()
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName(r.Method + " " + rctx.RoutePattern())
+			span.SetAttributes(semconv.HTTPRoute(rctx.RoutePattern()))
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
+
+// clientSpan tracks the span of an outgoing resty request. Retries run the
+// request hooks again, so the previous attempt's span is ended and the new
+// one started from the original parent.
+type clientSpan struct {
+	parent context.Context
+	span   trace.Span
+}
+
+type clientSpanKey struct{}
+
+// InstrumentResty wraps every request made by client in a client span and
+// propagates the trace context in its headers. Only the method and redacted
+// URL are recorded, never the body.
+func InstrumentResty(client *resty.Client) *resty.Client {
+	client.OnBeforeRequest(func(_ *resty.Client, req *resty.Request) error {
+		parent := req.Context()
+		if previous, ok := parent.Value(clientSpanKey{}).(*clientSpan); ok {
+			previous.span.End()
+			parent = previous.parent
+		}
+
+		target := req.URL
+		if parsed, err := url.Parse(req.URL); err == nil {
+			target = parsed.Redacted()
+		}
+		ctx, span := Tracer.Start(parent, "HTTP "+req.Method,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(req.Method),
+				semconv.URLFull(target),
+			),
+		)
+		req.SetContext(context.WithValue(ctx, clientSpanKey{}, &clientSpan{parent: parent, span: span}))
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		return nil
+	})
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		if current, ok := resp.Request.Context().Value(clientSpanKey{}).(*clientSpan); ok {
+			current.span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode()))
+			if resp.StatusCode() >= 400 {
+				current.span.SetStatus(codes.Error, resp.Status())
+			}
+			current.span.End()
+		}
+		return nil
+	})
+	client.OnError(func(req *resty.Request, err error) {
+		if current, ok := req.Context().Value(clientSpanKey{}).(*clientSpan); ok {
+			End(current.span, err)
+		}
+	})
+	return client
+}
@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Scorer compares a stored vector with a guess. Higher scores mean more similar.
+// Scores are in the scorer's own units, which aggregators work in; Cosine maps
+// a score back to the cosine similarity it corresponds to for unit vectors,
+// so one threshold and one set of reports apply whichever scorer is used.
+//
+// Every scorer's score is a monotonic function of the cosine, so under
+// MaxAggregator all of them make the same decision. They only differ under
+// aggregators that average, where averaging in each scorer's units weighs
+// near and far vectors differently.
+type Scorer interface {
+	Name() string
+	Score(a, b []float32) (float64, error)
+	Cosine(score float64) float64
+}
+
+// Aggregator combines the scores of a guess against each of a user's vectors
+type Aggregator interface {
+	Name() string
+	Aggregate(scores []float64) float64
+}
+
+// CosineScorer is cosine similarity, in [-1, 1]
+type CosineScorer struct{}
+
+func (CosineScorer) Name() string { return "cosine" }
+
+func (CosineScorer) Score(a, b []float32) (float64, error) {
+	return CosineSimilarity32(a, b)
+}
+
+func (CosineScorer) Cosine(score float64) float64 { return score }
+
+// DotScorer is the plain dot product. It equals cosine similarity only for
+// unit vectors, so it relies on ScoreMulti normalizing them first.
+type DotScorer struct{}
+
+func (DotScorer) Name() string { return "dot" }
+
+func (DotScorer) Score(a, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("vector length mismatch")
+	}
+
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return float64(dot), nil
+}
+
+func (DotScorer) Cosine(score float64) float64 { return score }
+
+// EuclideanScorer maps Euclidean distance d to a similarity of 1 / (1 + d), in (0, 1].
+// Like DotScorer it assumes unit vectors.
+type EuclideanScorer struct{}
+
+func (EuclideanScorer) Name() string { return "euclidean" }
+
+func (EuclideanScorer) Score(a, b []float32) (float64, error) {
+	if len(a) != len(b) {
+		return 0, errors.New("vector length mismatch")
+	}
+
+	var sum float64
+	for i := range a {
+		d := float64(a[i] - b[i])
+		sum += d * d
+	}
+	return 1 / (1 + math.Sqrt(sum)), nil
+}
+
+// Cosine inverts 1 / (1 + d) and uses |a - b|² = 2 - 2cos for unit vectors
+func (EuclideanScorer) Cosine(score float64) float64 {
+	if score <= 0 {
+		return -1
+	}
+	d := 1/score - 1
+	return math.Max(-1, 1-d*d/2)
+}
+
+// AngularScorer is 1 - θ/π for the angle θ between the vectors, in [0, 1]
+type AngularScorer struct{}
+
+func (AngularScorer) Name() string { return "angular" }
+
+func (AngularScorer) Score(a, b []float32) (float64, error) {
+	cos, err := CosineSimilarity32(a, b)
+	if err != nil {
+		return 0, err
+	}
+	cos = math.Max(-1, math.Min(1, cos))
+	return 1 - math.Acos(cos)/math.Pi, nil
+}
+
+func (AngularScorer) Cosine(score float64) float64 {
+	return math.Cos(math.Pi * (1 - score))
+}
+
+// MaxAggregator takes the best score
+type MaxAggregator struct{}
+
+func (MaxAggregator) Name() string { return "max" }
+
+func (MaxAggregator) Aggregate(scores []float64) float64 {
+	best := math.Inf(-1)
+	for _, s := range scores {
+		best = math.Max(best, s)
+	}
+	return best
+}
+
+// MeanAggregator averages all scores
+type MeanAggregator struct{}
+
+func (MeanAggregator) Name() string { return "mean" }
+
+func (MeanAggregator) Aggregate(scores []float64) float64 {
+	var sum float64
+	for _, s := range scores {
+		sum += s
+	}
+	return sum / float64(len(scores))
+}
+
+// TopKMeanAggregator averages the K best scores, or all of them if there are fewer
+type TopKMeanAggregator struct {
+	K int
+}
+
+func (a TopKMeanAggregator) Name() string { return fmt.Sprintf("topk:%d", a.K) }
+
+func (a TopKMeanAggregator) Aggregate(scores []float64) float64 {
+	sorted := append([]float64(nil), scores...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	if a.K < len(sorted) {
+		sorted = sorted[:a.K]
+	}
+	return MeanAggregator{}.Aggregate(sorted)
+}
+
+// ScorerByName returns the scorer for "cosine", "dot", "euclidean" or "angular"
+func ScorerByName(name string) (Scorer, error) {
+	switch name {
+	case "cosine":
+		return CosineScorer{}, nil
+	case "dot":
+		return DotScorer{}, nil
+	case "euclidean":
+		return EuclideanScorer{}, nil
+	case "angular":
+		return AngularScorer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown scorer %q", name)
+	}
+}
+
+// AggregatorByName returns the aggregator for "max", "mean" or "topk:<k>"
+func AggregatorByName(name string) (Aggregator, error) {
+	switch {
+	case name == "max":
+		return MaxAggregator{}, nil
+	case name == "mean":
+		return MeanAggregator{}, nil
+	case strings.HasPrefix(name, "topk:"):
+		k, err := strconv.Atoi(strings.TrimPrefix(name, "topk:"))
+		if err != nil || k <= 0 {
+			return nil, fmt.Errorf("invalid top-k aggregator %q", name)
+		}
+		return TopKMeanAggregator{K: k}, nil
+	default:
+		return nil, fmt.Errorf("unknown aggregator %q", name)
+	}
+}
+
+// ScoringFor returns the named scorer and aggregator in place of the given
+// ones. An empty name keeps the given one, and so does an unknown name, which
+// is also reported in the error.
+func ScoringFor(scorerName, aggregatorName string, scorer Scorer, aggregator Aggregator) (Scorer, Aggregator, error) {
+	var errs []error
+	if scorerName != "" {
+		if s, err := ScorerByName(scorerName); err == nil {
+			scorer = s
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	if aggregatorName != "" {
+		if a, err := AggregatorByName(aggregatorName); err == nil {
+			aggregator = a
+		} else {
+			errs = append(errs, err)
+		}
+	}
+	return scorer, aggregator, errors.Join(errs...)
+}
+
+// ScoreMulti scores a guess against each stored vector, aggregates the
+// results and returns the aggregate on the cosine scale. Vectors of different
+// lengths are compared via MatchDimensions. Both vectors are normalized
+// first: int8 and truncated vectors are only approximately unit length, and
+// the dot and Euclidean scores and every Cosine mapping assume exactly that.
+func ScoreMulti(scorer Scorer, aggregator Aggregator, stored [][]float32, guess []float32) (float64, error) {
+	if len(stored) == 0 {
+		return 0, errors.New("no stored vectors")
+	}
+
+	scores := make([]float64, 0, len(stored))
+	for _, vector := range stored {
+		a, b := MatchDimensions(vector, guess)
+		score, err := scorer.Score(Normalize(a), Normalize(b))
+		if err != nil {
+			return 0, err
+		}
+		scores = append(scores, score)
+	}
+
+	return scorer.Cosine(aggregator.Aggregate(scores)), nil
+}
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"semantic-auth/internal/testvec"
+)
+
+var allScorers = []Scorer{CosineScorer{}, DotScorer{}, EuclideanScorer{}, AngularScorer{}}
+
+// TestScorersShareTheCosineScale checks that every scorer reports the cosine
+// similarity for unit vectors, so LOGIN_THRESHOLD means the same whichever
+// scorer a user has
+func TestScorersShareTheCosineScale(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 200; i++ {
+		a := testvec.EmbeddingLike(rng, 1536, 8)
+		b := testvec.NearDuplicate(rng, a, 0.5+0.49*rng.Float64())
+		want, _ := CosineSimilarity(a, b)
+
+		for _, scorer := range allScorers {
+			got, err := ScoreMulti(scorer, MaxAggregator{}, [][]float32{testvec.Float32(a)}, testvec.Float32(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-want) > 1e-4 {
+				t.Errorf("%s: got %.6f for a pair with cosine %.6f", scorer.Name(), got, want)
+			}
+		}
+	}
+}
+
+// TestScorersNormalize checks vectors that are only approximately unit
+// length, as int8 and truncated vectors are, still score their cosine
+func TestScorersNormalize(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for i := 0; i < 50; i++ {
+		a := testvec.EmbeddingLike(rng, 1536, 8)
+		b := testvec.NearDuplicate(rng, a, 0.7+0.29*rng.Float64())
+		want, _ := CosineSimilarity(a, b)
+
+		stored, guess := testvec.Float32(a), testvec.Float32(b)
+		for j := range stored {
+			stored[j] *= 1.02
+		}
+		for j := range guess {
+			guess[j] *= 0.97
+		}
+
+		for _, scorer := range allScorers {
+			got, err := ScoreMulti(scorer, MaxAggregator{}, [][]float32{stored}, guess)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-want) > 1e-4 {
+				t.Errorf("%s: got %.6f for scaled vectors with cosine %.6f", scorer.Name(), got, want)
+			}
+		}
+	}
+}
+
+// TestScorersDivergeOnlyWhenAveraging checks that the scorer changes the
+// result under mean and top-k aggregation but not under max, where every
+// scorer ranks vectors by their cosine
+func TestScorersDivergeOnlyWhenAveraging(t *testing.T) {
+	guess := []float32{1, 0}
+	var stored [][]float32
+	for _, cos := range []float64{0.95, 0.6, -0.2} {
+		stored = append(stored, []float32{float32(cos), float32(math.Sqrt(1 - cos*cos))})
+	}
+
+	results := func(aggregator Aggregator) map[string]float64 {
+		out := map[string]float64{}
+		for _, scorer := range allScorers {
+			got, err := ScoreMulti(scorer, aggregator, stored, guess)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out[scorer.Name()] = got
+		}
+		return out
+	}
+
+	for name, got := range results(MaxAggregator{}) {
+		if math.Abs(got-0.95) > 1e-5 {
+			t.Errorf("max with %s = %.6f, want the best cosine 0.95", name, got)
+		}
+	}
+
+	for _, aggregator := range []Aggregator{MeanAggregator{}, TopKMeanAggregator{K: 2}} {
+		got := results(aggregator)
+		// The dot product is the cosine, so those two still agree
+		if math.Abs(got["cosine"]-got["dot"]) > 1e-5 {
+			t.Errorf("%s: cosine %.6f and dot %.6f differ", aggregator.Name(), got["cosine"], got["dot"])
+		}
+		for _, pair := range [][2]string{{"cosine", "euclidean"}, {"cosine", "angular"}, {"euclidean", "angular"}} {
+			if math.Abs(got[pair[0]]-got[pair[1]]) < 1e-3 {
+				t.Errorf("%s: %s %.6f and %s %.6f agree", aggregator.Name(), pair[0], got[pair[0]], pair[1], got[pair[1]])
+			}
+		}
+	}
+}
+
+func TestScorerCosineInvertsScore(t *testing.T) {
+	for _, cos := range []float64{-1, -0.5, 0, 0.5, 0.88, 0.95, 1} {
+		// Two unit vectors at exactly this cosine
+		a := []float32{1, 0}
+		b := []float32{float32(cos), float32(math.Sqrt(1 - cos*cos))}
+
+		for _, scorer := range allScorers {
+			score, err := scorer.Score(a, b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := scorer.Cosine(score); math.Abs(got-cos) > 1e-5 {
+				t.Errorf("%s: Cosine(Score) = %.6f, want %.6f", scorer.Name(), got, cos)
+			}
+		}
+	}
+}
+
+// TestAggregatorsWorkInScorerUnits checks that means are taken before mapping
+// to the cosine scale, which is what makes the scorers differ under mean and
+// top-k aggregation
+func TestAggregatorsWorkInScorerUnits(t *testing.T) {
+	guess := []float32{1, 0}
+	stored := [][]float32{
+		{1, 0},
+		{0, 1},
+	}
+
+	// Angles 0 and π/2 average to π/4, cosines 1 and 0 average to 0.5
+	got, err := ScoreMulti(AngularScorer{}, MeanAggregator{}, stored, guess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := math.Cos(math.Pi / 4); math.Abs(got-want) > 1e-6 {
+		t.Errorf("angular mean = %.6f, want %.6f", got, want)
+	}
+
+	got, err = ScoreMulti(CosineScorer{}, MeanAggregator{}, stored, guess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-0.5) > 1e-6 {
+		t.Errorf("cosine mean = %.6f, want 0.5", got)
+	}
+
+	if got := (TopKMeanAggregator{K: 2}).Aggregate([]float64{0.1, 0.9, 0.5}); math.Abs(got-0.7) > 1e-9 {
+		t.Errorf("top-2 mean = %v, want 0.7", got)
+	}
+}
+
+func TestScorerAndAggregatorNames(t *testing.T) {
+	for _, scorer := range allScorers {
+		got, err := ScorerByName(scorer.Name())
+		if err != nil || got.Name() != scorer.Name() {
+			t.Errorf("ScorerByName(%q) = %v, %v", scorer.Name(), got, err)
+		}
+	}
+	for _, name := range []string{"max", "mean", "topk:3"} {
+		if a, err := AggregatorByName(name); err != nil || a.Name() != name {
+			t.Errorf("AggregatorByName(%q) = %v, %v", name, a, err)
+		}
+	}
+	for _, name := range []string{"topk:0", "topk:x", "median"} {
+		if _, err := AggregatorByName(name); err == nil {
+			t.Errorf("AggregatorByName(%q) accepted an invalid name", name)
+		}
+	}
+	if _, err := ScorerByName("manhattan"); err == nil {
+		t.Error("ScorerByName accepted an unknown scorer")
+	}
+}
+
+func TestScoringFor(t *testing.T) {
+	cases := []struct {
+		scorer, aggregator string
+		want               [2]string
+		err                bool
+	}{
+		{"", "", [2]string{"cosine", "max"}, false},
+		{"angular", "topk:2", [2]string{"angular", "topk:2"}, false},
+		{"hamming", "mean", [2]string{"cosine", "mean"}, true},
+		{"dot", "median", [2]string{"dot", "max"}, true},
+	}
+	for _, tc := range cases {
+		scorer, aggregator, err := ScoringFor(tc.scorer, tc.aggregator, CosineScorer{}, MaxAggregator{})
+		if got := [2]string{scorer.Name(), aggregator.Name()}; got != tc.want || (err != nil) != tc.err {
+			t.Errorf("ScoringFor(%q, %q) = %v, %v, want %v", tc.scorer, tc.aggregator, got, err, tc.want)
+		}
+	}
+}
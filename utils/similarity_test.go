@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"semantic-auth/internal/testvec"
+)
+
+func TestCosineSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{1, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 1}, 0},
+		{[]float64{1, 0}, []float64{-1, 0}, -1},
+		{[]float64{3, 4}, []float64{6, 8}, 1},
+	}
+	for _, c := range cases {
+		got, err := CosineSimilarity(c.a, c.b)
+		if err != nil || math.Abs(got-c.want) > 1e-12 {
+			t.Errorf("CosineSimilarity(%v, %v) = %v, %v; want %v", c.a, c.b, got, err, c.want)
+		}
+		got, err = CosineSimilarity32(testvec.Float32(c.a), testvec.Float32(c.b))
+		if err != nil || math.Abs(got-c.want) > 1e-6 {
+			t.Errorf("CosineSimilarity32(%v, %v) = %v, %v; want %v", c.a, c.b, got, err, c.want)
+		}
+	}
+
+	if _, err := CosineSimilarity32([]float32{1}, []float32{1, 2}); err == nil {
+		t.Error("length mismatch was not reported")
+	}
+	if _, err := CosineSimilarity32([]float32{0, 0}, []float32{1, 2}); err == nil {
+		t.Error("zero vector was not reported")
+	}
+}
+
+// TestCosineSimilarity32Accuracy bounds the float32 path against float64 on
+// near-duplicate 1536-dimension pairs around the login threshold
+func TestCosineSimilarity32Accuracy(t *testing.T) {
+	const (
+		pairs = 2000
+		dims  = 1536
+		bound = 1e-5
+	)
+	rng := rand.New(rand.NewSource(1))
+
+	var maxErr, sumErr float64
+	for i := 0; i < pairs; i++ {
+		a := testvec.EmbeddingLike(rng, dims, 8)
+		b := testvec.NearDuplicate(rng, a, 0.80+0.19*rng.Float64())
+
+		want, err := CosineSimilarity(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := CosineSimilarity32(testvec.Float32(a), testvec.Float32(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		diff := math.Abs(got - want)
+		maxErr = math.Max(maxErr, diff)
+		sumErr += diff
+	}
+
+	t.Logf("float32 vs float64 over %d pairs: max error %.1e, mean error %.1e", pairs, maxErr, sumErr/pairs)
+	if maxErr >= bound {
+		t.Errorf("max error %.2e, want < %.0e", maxErr, bound)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	v := testvec.Float32(testvec.EmbeddingLike(rand.New(rand.NewSource(2)), 1536, 8))
+
+	short := Truncate(v, 256)
+	if len(short) != 256 {
+		t.Fatalf("len = %d, want 256", len(short))
+	}
+
+	var mag float64
+	for _, f := range short {
+		mag += float64(f) * float64(f)
+	}
+	if math.Abs(mag-1) > 1e-5 {
+		t.Errorf("truncated vector has squared magnitude %v, want 1", mag)
+	}
+
+	// Same direction as the prefix
+	if cos, _ := CosineSimilarity32(short, v[:256]); math.Abs(cos-1) > 1e-6 {
+		t.Errorf("truncated vector is not the rescaled prefix: cosine %v", cos)
+	}
+
+	if got := Truncate(v, 0); len(got) != len(v) {
+		t.Error("Truncate(v, 0) should return v unchanged")
+	}
+
+	a, b := MatchDimensions(v, short)
+	if len(a) != 256 || len(b) != 256 {
+		t.Errorf("MatchDimensions lengths %d and %d, want 256", len(a), len(b))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := []float32{3, 4}
+	got := Normalize(v)
+	if math.Abs(float64(got[0])-0.6) > 1e-6 || math.Abs(float64(got[1])-0.8) > 1e-6 {
+		t.Errorf("Normalize([3 4]) = %v, want [0.6 0.8]", got)
+	}
+	if v[0] != 3 {
+		t.Error("Normalize modified its argument")
+	}
+	if got := Normalize([]float32{0, 0}); got[0] != 0 || got[1] != 0 {
+		t.Errorf("Normalize of a zero vector = %v", got)
+	}
+}
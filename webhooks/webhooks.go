@@ -0,0 +1,361 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"semantic-auth/db"
+	"semantic-auth/events"
+	"semantic-auth/models"
+	"semantic-auth/tracing"
+
+	"github.com/go-resty/resty/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// workers is the number of concurrent deliveries
+	workers = 4
+
+	// maxAttempts is how many times a delivery is tried before it is dead-lettered
+	maxAttempts = 5
+
+	// queueSize bounds deliveries waiting for a worker
+	queueSize = 1024
+
+	// subscriptionsTTL bounds how long a subscription changed on another
+	// replica can go unnoticed; changes made here take effect at once
+	subscriptionsTTL = time.Minute
+)
+
+// initialBackoff doubles after each failed attempt
+var initialBackoff = time.Second
+
+var client = tracing.InstrumentResty(resty.New()).SetTimeout(10 * time.Second)
+
+// Payload is the JSON body posted to subscribers
+type Payload struct {
+	ID        string      `json:"id"`
+	Type      events.Type `json:"type"`
+	Username  string      `json:"username"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+type delivery struct {
+	subscription models.WebhookSubscription
+	eventType    events.Type
+	body         []byte
+}
+
+var (
+	queue        = make(chan delivery, queueSize)
+	subscription *events.Subscription
+	running      sync.WaitGroup
+
+	// stopCtx is cancelled when Shutdown runs out of time, abandoning retries
+	stopCtx, stop = context.WithCancel(context.Background())
+
+	// Active subscriptions, reloaded when older than subscriptionsTTL
+	subscriptionsMu     sync.Mutex
+	subscriptions       []models.WebhookSubscription
+	subscriptionsLoaded time.Time
+
+	// loadSubscriptions and storeDeadLetter are the storage used by
+	// dispatch and deadLetter
+	loadSubscriptions = findActiveSubscriptions
+	storeDeadLetter   = insertDeadLetter
+)
+
+// Start subscribes to the event bus and starts the delivery workers
+func Start() {
+	subscription = events.Default.Subscribe("webhooks", queueSize, dropped)
+
+	go func() {
+		for event := range subscription.C {
+			dispatch(event)
+		}
+		close(queue)
+	}()
+
+	for i := 0; i < workers; i++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			for d := range queue {
+				deliver(d)
+			}
+		}()
+	}
+}
+
+// Shutdown stops taking new events and waits for queued deliveries, including
+// their retries, until ctx is done. After that, remaining deliveries stop
+// retrying and are dead-lettered as the workers reach them.
+func Shutdown(ctx context.Context) error {
+	if subscription == nil {
+		return nil
+	}
+	subscription.Close()
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		stop()
+		return fmt.Errorf("%d webhook deliveries still queued: %w", len(queue), ctx.Err())
+	}
+}
+
+// Wait waits until ctx is done for the workers to exit after Shutdown. Once
+// retries have been abandoned this is the time taken to dead-letter whatever
+// was still queued, which needs MongoDB to still be connected.
+func Wait(ctx context.Context) error {
+	if subscription == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d webhook deliveries not dead-lettered: %w", len(queue), ctx.Err())
+	}
+}
+
+// Invalidate drops the cached subscriptions, so the next event reloads them.
+// The webhook handlers call it after changing a subscription.
+func Invalidate() {
+	subscriptionsMu.Lock()
+	subscriptionsLoaded = time.Time{}
+	subscriptionsMu.Unlock()
+}
+
+// subscribersTo returns the active subscriptions that want eventType
+func subscribersTo(eventType events.Type) ([]models.WebhookSubscription, error) {
+	subscriptionsMu.Lock()
+	defer subscriptionsMu.Unlock()
+
+	if time.Since(subscriptionsLoaded) > subscriptionsTTL {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		loaded, err := loadSubscriptions(ctx)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		subscriptions, subscriptionsLoaded = loaded, time.Now()
+	}
+
+	var matched []models.WebhookSubscription
+	for _, s := range subscriptions {
+		if wants(s, eventType) {
+			matched = append(matched, s)
+		}
+	}
+	return matched, nil
+}
+
+// wants reports whether a subscription is for eventType; no events means all
+func wants(s models.WebhookSubscription, eventType events.Type) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+	for _, e := range s.Events {
+		if e == string(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+func findActiveSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	cursor, err := db.Client.Database("semantic_auth").Collection("webhook_subscriptions").
+		Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, fmt.Errorf("query webhook subscriptions: %w", err)
+	}
+	var loaded []models.WebhookSubscription
+	if err := cursor.All(ctx, &loaded); err != nil {
+		return nil, fmt.Errorf("read webhook subscriptions: %w", err)
+	}
+	return loaded, nil
+}
+
+// deliveries builds one delivery of event per subscription that wants it
+func deliveries(event events.Event) []delivery {
+	subscribers, err := subscribersTo(event.Type)
+	if err != nil {
+		slog.Error("Failed to load webhook subscriptions", "error", err)
+		return nil
+	}
+	if len(subscribers) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(Payload{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      event.Type,
+		Username:  event.Username,
+		Timestamp: event.Timestamp,
+		Data:      sanitize(event.Data),
+	})
+	if err != nil {
+		slog.Error("Failed to marshal webhook payload", "error", err)
+		return nil
+	}
+
+	out := make([]delivery, len(subscribers))
+	for i, s := range subscribers {
+		out[i] = delivery{subscription: s, eventType: event.Type, body: body}
+	}
+	return out
+}
+
+// dispatch queues an event for every active subscription that wants it
+func dispatch(event events.Event) {
+	for _, d := range deliveries(event) {
+		select {
+		case queue <- d:
+		default:
+			deadLetter(d, 0, fmt.Errorf("delivery queue full"))
+		}
+	}
+}
+
+// dropped dead-letters an event the bus had no room for. The bus calls it
+// while publishing, so the work happens on a worker-tracked goroutine.
+func dropped(event events.Event) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		for _, d := range deliveries(event) {
+			deadLetter(d, 0, fmt.Errorf("event buffer full"))
+		}
+	}()
+}
+
+// sanitize converts event data to a payload that never includes phrases
+func sanitize(data interface{}) interface{} {
+	switch d := data.(type) {
+	case models.LoginAttempt:
+		return map[string]interface{}{
+			"similarity": d.Similarity,
+			"threshold":  d.Threshold,
+			"passed":     d.Passed,
+			"scorer":     d.Scorer,
+		}
+	case models.ModerationEvent:
+		return map[string]interface{}{
+			"category":        d.Category,
+			"categories":      d.Categories,
+			"service_version": d.ServiceVersion,
+		}
+	default:
+		return data
+	}
+}
+
+// Sign returns the signature header value for a body sent at timestamp.
+// Receivers recompute HMAC-SHA256 over "<timestamp>.<body>" with their secret.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliver posts a payload, retrying with exponential backoff before dead-lettering it
+func deliver(d delivery) {
+	backoff := initialBackoff
+	var lastErr error
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		timestamp := time.Now().Unix()
+		resp, err := client.R().
+			SetContext(stopCtx).
+			SetHeader("Content-Type", "application/json").
+			SetHeader("X-SemanticAuth-Event", string(d.eventType)).
+			SetHeader("X-SemanticAuth-Timestamp", strconv.FormatInt(timestamp, 10)).
+			SetHeader("X-SemanticAuth-Signature", Sign(d.subscription.Secret, timestamp, d.body)).
+			SetBody(d.body).
+			Post(d.subscription.URL)
+
+		if err == nil && resp.StatusCode() < 300 {
+			return
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("subscriber returned status %d", resp.StatusCode())
+		}
+
+		if attempt < maxAttempts {
+			select {
+			case <-time.After(backoff):
+				backoff *= 2
+			case <-stopCtx.Done():
+				deadLetter(d, attempt, lastErr)
+				return
+			}
+		}
+	}
+
+	deadLetter(d, maxAttempts, lastErr)
+}
+
+// deadLetter records a delivery that could not be made
+func deadLetter(d delivery, attempts int, lastErr error) {
+	slog.Warn("Webhook delivery failed", "url", d.subscription.URL, "attempts", attempts, "error", lastErr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := storeDeadLetter(ctx, models.WebhookDeadLetter{
+		SubscriptionID: d.subscription.ID,
+		URL:            d.subscription.URL,
+		EventType:      string(d.eventType),
+		Payload:        string(d.body),
+		Attempts:       attempts,
+		LastError:      lastErr.Error(),
+		FailedAt:       time.Now(),
+	})
+	if err != nil {
+		slog.Error("Failed to store webhook dead letter", "error", err)
+	}
+}
+
+func insertDeadLetter(ctx context.Context, letter models.WebhookDeadLetter) error {
+	_, err := db.Client.Database("semantic_auth").Collection("webhook_dead_letters").InsertOne(ctx, letter)
+	return err
+}
+
+// NewSecret generates a random signing secret
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,187 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"semantic-auth/events"
+	"semantic-auth/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// receiver is a webhook subscriber answering with statuses in turn, repeating the last
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	arrivals []time.Time
+	bodies   []string
+	headers  []http.Header
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, string) {
+	t.Helper()
+	rec := &receiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		n := len(rec.arrivals)
+		rec.arrivals = append(rec.arrivals, time.Now())
+		rec.bodies = append(rec.bodies, string(body))
+		rec.headers = append(rec.headers, r.Header.Clone())
+		rec.mu.Unlock()
+		w.WriteHeader(rec.statuses[min(n, len(rec.statuses)-1)])
+	}))
+	t.Cleanup(server.Close)
+	return rec, server.URL
+}
+
+// captureDeadLetters records dead letters instead of storing them, and
+// shortens the retry backoff
+func captureDeadLetters(t *testing.T) *[]models.WebhookDeadLetter {
+	t.Helper()
+	var mu sync.Mutex
+	letters := &[]models.WebhookDeadLetter{}
+
+	savedStore, savedBackoff := storeDeadLetter, initialBackoff
+	storeDeadLetter = func(ctx context.Context, letter models.WebhookDeadLetter) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*letters = append(*letters, letter)
+		return nil
+	}
+	initialBackoff = 20 * time.Millisecond
+	t.Cleanup(func() { storeDeadLetter, initialBackoff = savedStore, savedBackoff })
+	return letters
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"type":"login.failed"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := Sign("secret", 1700000000, body); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("other", 1700000000, body) == want || Sign("secret", 1700000001, body) == want {
+		t.Error("signature does not depend on the secret and timestamp")
+	}
+}
+
+func TestDeliverSignsRequests(t *testing.T) {
+	letters := captureDeadLetters(t)
+	rec, url := newReceiver(t, http.StatusNoContent)
+
+	body := []byte(`{"id":"1"}`)
+	deliver(delivery{
+		subscription: models.WebhookSubscription{URL: url, Secret: "s3cret"},
+		eventType:    events.LoginSucceeded,
+		body:         body,
+	})
+
+	if len(rec.arrivals) != 1 || len(*letters) != 0 {
+		t.Fatalf("%d requests and %d dead letters, want 1 and 0", len(rec.arrivals), len(*letters))
+	}
+	h := rec.headers[0]
+	timestamp, err := strconv.ParseInt(h.Get("X-SemanticAuth-Timestamp"), 10, 64)
+	if err != nil {
+		t.Fatalf("bad timestamp header: %v", err)
+	}
+	if got := h.Get("X-SemanticAuth-Signature"); got != Sign("s3cret", timestamp, []byte(rec.bodies[0])) {
+		t.Errorf("signature %s does not verify", got)
+	}
+	if h.Get("X-SemanticAuth-Event") != string(events.LoginSucceeded) || rec.bodies[0] != string(body) {
+		t.Errorf("event %q, body %q", h.Get("X-SemanticAuth-Event"), rec.bodies[0])
+	}
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	letters := captureDeadLetters(t)
+	rec, url := newReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK)
+
+	deliver(delivery{subscription: models.WebhookSubscription{URL: url}, eventType: events.LoginFailed, body: []byte("{}")})
+
+	if len(rec.arrivals) != 3 || len(*letters) != 0 {
+		t.Fatalf("%d requests and %d dead letters, want 3 and 0", len(rec.arrivals), len(*letters))
+	}
+	// The backoff doubles: 20ms, then 40ms
+	for i, min := range []time.Duration{initialBackoff, 2 * initialBackoff} {
+		if gap := rec.arrivals[i+1].Sub(rec.arrivals[i]); gap < min {
+			t.Errorf("retry %d after %v, want at least %v", i+1, gap, min)
+		}
+	}
+}
+
+func TestDeliverDeadLettersAfterMaxAttempts(t *testing.T) {
+	letters := captureDeadLetters(t)
+	rec, url := newReceiver(t, http.StatusBadGateway)
+
+	id := primitive.NewObjectID()
+	deliver(delivery{subscription: models.WebhookSubscription{ID: id, URL: url}, eventType: events.AccountLocked, body: []byte(`{"id":"2"}`)})
+
+	if len(rec.arrivals) != maxAttempts {
+		t.Errorf("%d requests, want %d", len(rec.arrivals), maxAttempts)
+	}
+	if len(*letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(*letters))
+	}
+	letter := (*letters)[0]
+	if letter.SubscriptionID != id || letter.URL != url || letter.EventType != string(events.AccountLocked) ||
+		letter.Payload != `{"id":"2"}` || letter.Attempts != maxAttempts || !strings.Contains(letter.LastError, "502") {
+		t.Errorf("dead letter %+v", letter)
+	}
+}
+
+func TestDroppedEventsAreDeadLettered(t *testing.T) {
+	letters := captureDeadLetters(t)
+
+	var loads int
+	savedLoad := loadSubscriptions
+	loadSubscriptions = func(ctx context.Context) ([]models.WebhookSubscription, error) {
+		loads++
+		return []models.WebhookSubscription{
+			{URL: "http://all.example", Events: []string{}},
+			{URL: "http://locks.example", Events: []string{string(events.AccountLocked)}},
+			{URL: "http://logins.example", Events: []string{string(events.LoginFailed)}},
+		}, nil
+	}
+	t.Cleanup(func() { loadSubscriptions = savedLoad; Invalidate() })
+	Invalidate()
+
+	dropped(events.Event{Type: events.LoginFailed, Username: "steve", Timestamp: time.Now()})
+	running.Wait()
+
+	if len(*letters) != 2 {
+		t.Fatalf("%d dead letters, want one each for the all-events and login subscriptions", len(*letters))
+	}
+	for _, letter := range *letters {
+		if letter.URL == "http://locks.example" || letter.Attempts != 0 || letter.LastError != "event buffer full" {
+			t.Errorf("dead letter %+v", letter)
+		}
+		if !strings.Contains(letter.Payload, `"username":"steve"`) {
+			t.Errorf("payload %s", letter.Payload)
+		}
+	}
+
+	// Subscriptions come from the cache until invalidated
+	subscribersTo(events.AccountLocked)
+	if loads != 1 {
+		t.Errorf("subscriptions loaded %d times, want 1", loads)
+	}
+	Invalidate()
+	subscribersTo(events.AccountLocked)
+	if loads != 2 {
+		t.Errorf("subscriptions loaded %d times after Invalidate, want 2", loads)
+	}
+}